@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The binary segment format starts with a magic string and a version byte, followed by the records.
+// Each record is framed by its length (uvarint) and followed by the CRC-32C of its payload (big-endian).
+// The payload is a sequence of varints and length-prefixed byte slices, a length of -1 meaning null:
+//
+//	partition offset timestamp keyLength key valueLength value headerCount [headerKeyLength headerKey headerValueLength headerValue]...
+var binaryMagic = []byte("KTCSEG")
+
+const binaryVersion = 1
+
+// maxBinaryRecordSize protects from allocating huge buffers when reading a corrupted length
+const maxBinaryRecordSize = 256 * 1024 * 1024
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type binaryWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func newBinaryWriter(w io.Writer) (*binaryWriter, error) {
+	if _, err := w.Write(append(append([]byte{}, binaryMagic...), binaryVersion)); err != nil {
+		return nil, err
+	}
+	return &binaryWriter{w: w}, nil
+}
+
+func (w *binaryWriter) Write(r Record) error {
+	w.buf.Reset()
+	w.varint(int64(r.Partition))
+	w.varint(r.Offset)
+	w.varint(timestampMillis(r.Timestamp))
+	w.bytes(r.Key)
+	w.bytes(r.Value)
+	w.varint(int64(len(r.Headers)))
+	for _, h := range r.Headers {
+		w.varint(int64(len(h.Key)))
+		w.buf.Write(h.Key)
+		w.bytes(h.Value)
+	}
+
+	frame := make([]byte, 0, binary.MaxVarintLen64+w.buf.Len()+4)
+	n := binary.PutUvarint(w.tmp[:], uint64(w.buf.Len()))
+	frame = append(frame, w.tmp[:n]...)
+	frame = append(frame, w.buf.Bytes()...)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(w.buf.Bytes(), crc32c))
+	frame = append(frame, sum[:]...)
+
+	_, err := w.w.Write(frame)
+	return err
+}
+
+func (w *binaryWriter) varint(v int64) {
+	n := binary.PutVarint(w.tmp[:], v)
+	w.buf.Write(w.tmp[:n])
+}
+
+func (w *binaryWriter) bytes(b []byte) {
+	if b == nil {
+		w.varint(-1)
+		return
+	}
+	w.varint(int64(len(b)))
+	w.buf.Write(b)
+}
+
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func newBinaryReader(r io.Reader) (*binaryReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%v: missing header", ErrCorrupted)
+	}
+	if !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		return nil, fmt.Errorf("%v: not a binary segment", ErrCorrupted)
+	}
+	if header[len(binaryMagic)] != binaryVersion {
+		return nil, fmt.Errorf("%v: unsupported version %d", ErrCorrupted, header[len(binaryMagic)])
+	}
+	return &binaryReader{r: br}, nil
+}
+
+func (r *binaryReader) Read() (Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return Record{}, io.EOF
+	}
+	if err != nil || size > maxBinaryRecordSize {
+		return Record{}, fmt.Errorf("%v: invalid record length", ErrCorrupted)
+	}
+
+	frame := make([]byte, size+4)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		return Record{}, fmt.Errorf("%v: truncated record", ErrCorrupted)
+	}
+	payload := frame[:size]
+	if binary.BigEndian.Uint32(frame[size:]) != crc32.Checksum(payload, crc32c) {
+		return Record{}, fmt.Errorf("%v: checksum mismatch", ErrCorrupted)
+	}
+
+	d := binaryDecoder{b: payload}
+	rec := Record{
+		Partition: int32(d.varint()),
+		Offset:    d.varint(),
+		Timestamp: fromMillis(d.varint()),
+		Key:       d.bytes(),
+		Value:     d.bytes(),
+	}
+	for i := d.varint(); i > 0 && d.err == nil; i-- {
+		key := d.bytes()
+		rec.Headers = append(rec.Headers, Header{Key: key, Value: d.bytes()})
+	}
+	if d.err != nil {
+		return Record{}, d.err
+	}
+	return rec, nil
+}
+
+type binaryDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = fmt.Errorf("%v: invalid varint", ErrCorrupted)
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) bytes() []byte {
+	l := d.varint()
+	if d.err != nil || l < 0 {
+		return nil
+	}
+	if l > int64(len(d.b)) {
+		d.err = fmt.Errorf("%v: invalid length", ErrCorrupted)
+		return nil
+	}
+	b := d.b[:l:l]
+	d.b = d.b[l:]
+	return b
+}
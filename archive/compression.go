@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Possible file compressions
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compressions lists the supported file compressions
+var Compressions = []string{CompressionNone, CompressionGzip, CompressionZstd}
+
+var errUnknownCompression = fmt.Errorf("unknown file compression, possible values: %s", strings.Join(Compressions, ", "))
+
+// Extension returns the file extension of the given format and compression
+func Extension(format, compression string) string {
+	ext := "." + format
+	if format == FormatBinary {
+		ext = ".seg"
+	}
+	switch compression {
+	case CompressionGzip:
+		ext += ".gz"
+	case CompressionZstd:
+		ext += ".zst"
+	}
+	return ext
+}
+
+// ParseExtension returns the format and compression of a file from its name.
+// The compression is detected even when the format is not.
+func ParseExtension(name string) (format, compression string, ok bool) {
+	compression = CompressionNone
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		compression, name = CompressionGzip, strings.TrimSuffix(name, ".gz")
+	case strings.HasSuffix(name, ".zst"):
+		compression, name = CompressionZstd, strings.TrimSuffix(name, ".zst")
+	}
+	switch {
+	case strings.HasSuffix(name, ".jsonl"):
+		return FormatJSONL, compression, true
+	case strings.HasSuffix(name, ".seg"):
+		return FormatBinary, compression, true
+	}
+	return "", compression, false
+}
+
+// compress wraps w so that what is written to it is compressed.
+// Closing the returned writer flushes the compressor, it does not close w.
+func compress(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, errUnknownCompression
+}
+
+// decompress wraps r so that what is read from it is decompressed
+func decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return ioutil.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, errUnknownCompression
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Exporter writes the records of a topic into files, with a separate set of files per partition.
+// A partition's file is rotated once its size reaches the maximum file size.
+// Files are named <topic>-<partition>-<first offset><extension>, so that they sort in offset order.
+// A manifest listing the files and their offset ranges is written when the exporter is closed.
+type Exporter struct {
+	storage     Storage
+	topic       string
+	format      string
+	compression string
+	maxFileSize int64
+
+	files    map[int32]*exportFile
+	segments []Segment
+}
+
+type exportFile struct {
+	segment    Segment
+	file       io.WriteCloser
+	compressor io.WriteCloser
+	buffer     *bufio.Writer
+	counter    *countingWriter
+	writer     RecordWriter
+}
+
+// NewExporter returns an exporter writing the files to the given storage.
+// A maxFileSize of 0 disables the rotation.
+func NewExporter(storage Storage, topic, format, compression string, maxFileSize int64) (*Exporter, error) {
+	if !contains(Formats, format) {
+		return nil, errUnknownFormat
+	}
+	if !contains(Compressions, compression) {
+		return nil, errUnknownCompression
+	}
+	return &Exporter{
+		storage:     storage,
+		topic:       topic,
+		format:      format,
+		compression: compression,
+		maxFileSize: maxFileSize,
+		files:       make(map[int32]*exportFile),
+	}, nil
+}
+
+// Write appends the record to the current file of its partition
+func (e *Exporter) Write(r Record) error {
+	f, ok := e.files[r.Partition]
+	if !ok {
+		var err error
+		if f, err = e.open(r); err != nil {
+			return err
+		}
+		e.files[r.Partition] = f
+	}
+
+	if err := f.writer.Write(r); err != nil {
+		return err
+	}
+	f.segment.LastOffset = r.Offset
+	f.segment.Records++
+
+	if e.maxFileSize > 0 && f.size() >= e.maxFileSize {
+		delete(e.files, r.Partition)
+		return e.close(f)
+	}
+	return nil
+}
+
+// Close flushes and closes every open file, then writes the manifest
+func (e *Exporter) Close() error {
+	var firstErr error
+	for partition, f := range e.files {
+		if err := e.close(f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(e.files, partition)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return writeManifest(e.storage, e.Manifest())
+}
+
+// Manifest describes the files closed so far
+func (e *Exporter) Manifest() Manifest {
+	segments := make([]Segment, len(e.segments))
+	copy(segments, e.segments)
+	return newManifest(e.topic, e.format, e.compression, segments)
+}
+
+func (e *Exporter) close(f *exportFile) error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	f.segment.Bytes = f.counter.n
+	e.segments = append(e.segments, f.segment)
+	return nil
+}
+
+func (e *Exporter) open(r Record) (*exportFile, error) {
+	name := fmt.Sprintf("%s-%d-%020d%s", e.topic, r.Partition, r.Offset, Extension(e.format, e.compression))
+	file, err := e.storage.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &countingWriter{w: file}
+	compressor, err := compress(counter, e.compression)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	buffer := bufio.NewWriter(compressor)
+	writer, err := NewRecordWriter(buffer, e.format)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &exportFile{
+		segment:    Segment{Name: name, Partition: r.Partition, FirstOffset: r.Offset},
+		file:       file,
+		compressor: compressor,
+		buffer:     buffer,
+		counter:    counter,
+		writer:     writer,
+	}, nil
+}
+
+// size is the size of the file once the buffered bytes are flushed.
+// With compression, it lags behind what the compressor holds, so the rotation may happen slightly after the maximum file size is reached.
+func (f *exportFile) size() int64 {
+	return f.counter.n + int64(f.buffer.Buffered())
+}
+
+func (f *exportFile) close() error {
+	if err := f.buffer.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	if err := f.compressor.Close(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// countingWriter counts the bytes written to the file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+//+build unit
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memStorage keeps the files in memory
+type memStorage map[string]*bytes.Buffer
+
+type memFile struct {
+	*bytes.Buffer
+}
+
+func (memFile) Close() error { return nil }
+
+func (m memStorage) Create(name string) (io.WriteCloser, error) {
+	m[name] = new(bytes.Buffer)
+	return memFile{m[name]}, nil
+}
+
+func (m memStorage) List() ([]string, error) {
+	return m.names(), nil
+}
+
+func (m memStorage) Open(name string) (io.ReadCloser, error) {
+	b, ok := m[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.Bytes())), nil
+}
+
+func (m memStorage) names() []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestExporterRotation(t *testing.T) {
+	//Arrange
+	storage := memStorage{}
+	exporter, err := NewExporter(storage, "foo", FormatJSONL, CompressionNone, 140)
+	assert.NoError(t, err)
+
+	//Act
+	for offset := int64(0); offset < 5; offset++ {
+		assert.NoError(t, exporter.Write(Record{Partition: 0, Offset: offset, Value: []byte("a value of a few bytes")}))
+	}
+	assert.NoError(t, exporter.Write(Record{Partition: 1, Offset: 7, Value: []byte("bar")}))
+	assert.NoError(t, exporter.Close())
+
+	//Assert
+	assert.Equal(t, []string{
+		"foo-0-00000000000000000000.jsonl",
+		"foo-0-00000000000000000002.jsonl",
+		"foo-0-00000000000000000004.jsonl",
+		"foo-1-00000000000000000007.jsonl",
+		ManifestName,
+	}, storage.names())
+
+	reader, _ := NewRecordReader(storage["foo-0-00000000000000000002.jsonl"], FormatJSONL)
+	first, _ := reader.Read()
+	second, _ := reader.Read()
+	_, err = reader.Read()
+	assert.Equal(t, int64(2), first.Offset)
+	assert.Equal(t, int64(3), second.Offset)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestExporterWithoutRotation(t *testing.T) {
+	//Arrange
+	storage := memStorage{}
+	exporter, err := NewExporter(storage, "foo", FormatBinary, CompressionGzip, 0)
+	assert.NoError(t, err)
+
+	//Act
+	for offset := int64(0); offset < 100; offset++ {
+		assert.NoError(t, exporter.Write(Record{Partition: 0, Offset: offset, Value: []byte("value")}))
+	}
+	assert.NoError(t, exporter.Close())
+
+	//Assert
+	assert.Equal(t, []string{"foo-0-00000000000000000000.seg.gz", ManifestName}, storage.names())
+}
+
+func TestExporterManifest(t *testing.T) {
+	//Arrange
+	storage := memStorage{}
+	exporter, _ := NewExporter(storage, "foo", FormatJSONL, CompressionNone, 140)
+
+	//Act
+	for offset := int64(10); offset < 15; offset++ {
+		assert.NoError(t, exporter.Write(Record{Partition: 1, Offset: offset, Value: []byte("a value of a few bytes")}))
+	}
+	assert.NoError(t, exporter.Write(Record{Partition: 0, Offset: 3, Value: []byte("bar")}))
+	assert.NoError(t, exporter.Close())
+	m, err := ReadManifest(storage)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", m.Topic)
+	assert.Equal(t, FormatJSONL, m.Format)
+	assert.Equal(t, CompressionNone, m.Compression)
+	assert.Equal(t, []Partition{
+		{Partition: 0, FirstOffset: 3, LastOffset: 3, Records: 1},
+		{Partition: 1, FirstOffset: 10, LastOffset: 14, Records: 5},
+	}, m.Partitions)
+	assert.Len(t, m.Segments, 4)
+	assert.Equal(t, Segment{
+		Name:        "foo-1-00000000000000000012.jsonl",
+		Partition:   1,
+		FirstOffset: 12,
+		LastOffset:  13,
+		Records:     2,
+		Bytes:       int64(storage["foo-1-00000000000000000012.jsonl"].Len()),
+	}, m.Segments[2])
+}
+
+func TestNewExporterErrors(t *testing.T) {
+	//Act
+	_, formatErr := NewExporter(memStorage{}, "foo", "csv", CompressionNone, 0)
+	_, compressionErr := NewExporter(memStorage{}, "foo", FormatJSONL, "bzip2", 0)
+
+	//Assert
+	assert.Equal(t, errUnknownFormat, formatErr)
+	assert.Equal(t, errUnknownCompression, compressionErr)
+}
@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"io"
+	"sort"
+)
+
+// Source is where the archive files are read from
+type Source interface {
+	List() ([]string, error)
+	Open(name string) (io.ReadCloser, error)
+}
+
+// Import reads the archive files of the source in name order, and calls handle with each of their records.
+// The format and compression of the files are detected from their extension, files with an unknown extension being skipped.
+// They can be forced instead, in which case every file of the source is read.
+// When the source holds a manifest, only the files it lists are read, see ImportManifest.
+func Import(source Source, format, compression string, handle func(Record) error) error {
+	names, err := source.List()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == ManifestName {
+			return ImportManifest(source, handle)
+		}
+	}
+
+	for _, name := range names {
+		fileFormat, fileCompression, ok := ParseExtension(name)
+		if format != "" {
+			fileFormat, ok = format, true
+		}
+		if compression != "" {
+			fileCompression = compression
+		}
+		if !ok {
+			continue
+		}
+
+		if err := importFile(source, name, fileFormat, fileCompression, handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportManifest reads the files listed in the manifest of the source, partition by partition in offset order,
+// and calls handle with each of their records. It fails if one of the files is missing.
+func ImportManifest(source Source, handle func(Record) error) error {
+	m, err := ReadManifest(source)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range m.Segments {
+		if err := importFile(source, s.Name, m.Format, m.Compression, handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importFile(source Source, name, format, compression string, handle func(Record) error) error {
+	f, err := source.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ReadFile(f, format, compression, handle)
+}
+
+// ReadFile decodes the records of a single file, and calls handle with each of them
+func ReadFile(r io.Reader, format, compression string, handle func(Record) error) error {
+	decompressor, err := decompress(r, compression)
+	if err != nil {
+		return err
+	}
+	defer decompressor.Close()
+
+	reader, err := NewRecordReader(decompressor, format)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//+build unit
+
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	exporter, err := NewExporter(Dir(dir), "foo", FormatBinary, CompressionZstd, 64)
+	assert.NoError(t, err)
+	for offset := int64(0); offset < 10; offset++ {
+		assert.NoError(t, exporter.Write(Record{Partition: int32(offset % 2), Offset: offset, Value: []byte("value")}))
+	}
+	assert.NoError(t, exporter.Close())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.txt"), []byte("not an archive"), 0644))
+
+	//Act
+	var offsets []int64
+	err = Import(Dir(dir), "", "", func(r Record) error {
+		offsets = append(offsets, r.Offset)
+		return nil
+	})
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}, offsets)
+}
+
+func TestImportForcedFormat(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.txt")
+	assert.NoError(t, ioutil.WriteFile(fixture, []byte("{\"key\":\"foo\"}\n{\"key\":\"bar\"}\n"), 0644))
+
+	//Act
+	var detected, forced []string
+	detectedErr := Import(File(fixture), "", "", func(r Record) error {
+		detected = append(detected, string(r.Key))
+		return nil
+	})
+	forcedErr := Import(File(fixture), FormatJSONL, "", func(r Record) error {
+		forced = append(forced, string(r.Key))
+		return nil
+	})
+
+	//Assert
+	assert.NoError(t, detectedErr)
+	assert.Empty(t, detected)
+	assert.NoError(t, forcedErr)
+	assert.Equal(t, []string{"foo", "bar"}, forced)
+}
+
+func TestImportMissingSource(t *testing.T) {
+	//Act
+	err := Import(Dir("does-not-exist"), "", "", func(r Record) error { return nil })
+
+	//Assert
+	assert.Error(t, err)
+}
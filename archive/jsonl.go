@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// jsonlRecord is the JSON Lines representation of a record, one per line.
+// Keys, values and header values are written as strings when they are valid UTF-8,
+// and base64-encoded in the *Base64 fields otherwise. Null keys and values are omitted.
+// Every field is optional when reading, so that fixtures can be written by hand.
+type jsonlRecord struct {
+	Partition   int32         `json:"partition"`
+	Offset      int64         `json:"offset"`
+	Timestamp   int64         `json:"timestamp"`
+	Key         *string       `json:"key,omitempty"`
+	KeyBase64   *string       `json:"keyBase64,omitempty"`
+	Value       *string       `json:"value,omitempty"`
+	ValueBase64 *string       `json:"valueBase64,omitempty"`
+	Headers     []jsonlHeader `json:"headers,omitempty"`
+}
+
+type jsonlHeader struct {
+	Key         string  `json:"key"`
+	Value       *string `json:"value,omitempty"`
+	ValueBase64 *string `json:"valueBase64,omitempty"`
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonlWriter{encoder: encoder}
+}
+
+func (w *jsonlWriter) Write(r Record) error {
+	j := jsonlRecord{
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: timestampMillis(r.Timestamp),
+	}
+	j.Key, j.KeyBase64 = encodeBytes(r.Key)
+	j.Value, j.ValueBase64 = encodeBytes(r.Value)
+	for _, h := range r.Headers {
+		header := jsonlHeader{Key: string(h.Key)}
+		header.Value, header.ValueBase64 = encodeBytes(h.Value)
+		j.Headers = append(j.Headers, header)
+	}
+	return w.encoder.Encode(j)
+}
+
+func encodeBytes(b []byte) (plain, b64 *string) {
+	if b == nil {
+		return nil, nil
+	}
+	s := string(b)
+	if utf8.ValidString(s) {
+		return &s, nil
+	}
+	s = base64.StdEncoding.EncodeToString(b)
+	return nil, &s
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	// Records can be much larger than the default 64KB line limit
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	return &jsonlReader{scanner: scanner}
+}
+
+func (r *jsonlReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var j jsonlRecord
+		j.Timestamp = -1
+		if err := json.Unmarshal(line, &j); err != nil {
+			return Record{}, fmt.Errorf("%v: line %d: %v", ErrCorrupted, r.line, err)
+		}
+
+		rec := Record{
+			Partition: j.Partition,
+			Offset:    j.Offset,
+			Timestamp: fromMillis(j.Timestamp),
+		}
+		var err error
+		if rec.Key, err = decodeBytes(j.Key, j.KeyBase64); err != nil {
+			return Record{}, fmt.Errorf("%v: line %d: %v", ErrCorrupted, r.line, err)
+		}
+		if rec.Value, err = decodeBytes(j.Value, j.ValueBase64); err != nil {
+			return Record{}, fmt.Errorf("%v: line %d: %v", ErrCorrupted, r.line, err)
+		}
+		for _, h := range j.Headers {
+			value, err := decodeBytes(h.Value, h.ValueBase64)
+			if err != nil {
+				return Record{}, fmt.Errorf("%v: line %d: %v", ErrCorrupted, r.line, err)
+			}
+			rec.Headers = append(rec.Headers, Header{Key: []byte(h.Key), Value: value})
+		}
+		return rec, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func decodeBytes(plain, b64 *string) ([]byte, error) {
+	switch {
+	case plain != nil:
+		return []byte(*plain), nil
+	case b64 != nil:
+		return base64.StdEncoding.DecodeString(*b64)
+	}
+	return nil, nil
+}
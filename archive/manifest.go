@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// ManifestName is the name of the manifest file written along with the exported files
+const ManifestName = "manifest.json"
+
+// Manifest describes an export: the files it is made of, and the offset ranges they hold.
+// Importing from a source holding a manifest only reads the files it lists.
+type Manifest struct {
+	Topic       string      `json:"topic"`
+	Format      string      `json:"format"`
+	Compression string      `json:"compression"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Partitions  []Partition `json:"partitions"`
+	Segments    []Segment   `json:"segments"`
+}
+
+// Partition is the offset range exported for a partition
+type Partition struct {
+	Partition   int32 `json:"partition"`
+	FirstOffset int64 `json:"firstOffset"`
+	LastOffset  int64 `json:"lastOffset"`
+	Records     int64 `json:"records"`
+}
+
+// Segment is an exported file, holding records of a single partition
+type Segment struct {
+	Name        string `json:"name"`
+	Partition   int32  `json:"partition"`
+	FirstOffset int64  `json:"firstOffset"`
+	LastOffset  int64  `json:"lastOffset"`
+	Records     int64  `json:"records"`
+	Bytes       int64  `json:"bytes"`
+}
+
+func newManifest(topic, format, compression string, segments []Segment) Manifest {
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].Partition != segments[j].Partition {
+			return segments[i].Partition < segments[j].Partition
+		}
+		return segments[i].FirstOffset < segments[j].FirstOffset
+	})
+
+	m := Manifest{
+		Topic:       topic,
+		Format:      format,
+		Compression: compression,
+		CreatedAt:   time.Now().UTC(),
+		Segments:    segments,
+	}
+	for _, s := range segments {
+		last := len(m.Partitions) - 1
+		if last < 0 || m.Partitions[last].Partition != s.Partition {
+			m.Partitions = append(m.Partitions, Partition{Partition: s.Partition, FirstOffset: s.FirstOffset})
+			last++
+		}
+		m.Partitions[last].LastOffset = s.LastOffset
+		m.Partitions[last].Records += s.Records
+	}
+	return m
+}
+
+func writeManifest(storage Storage, m Manifest) error {
+	f, err := storage.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadManifest reads the manifest of an export
+func ReadManifest(source Source) (Manifest, error) {
+	var m Manifest
+	f, err := source.Open(ManifestName)
+	if err != nil {
+		return m, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(io.LimitReader(f, 64*1024*1024)).Decode(&m)
+	return m, err
+}
@@ -0,0 +1,124 @@
+// Package archive stores kafka records in files, to snapshot a topic and restore it later.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Record is a kafka record, along with its position in the topic
+type Record struct {
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Header is a record header
+type Header struct {
+	Key   []byte
+	Value []byte
+}
+
+// Possible file formats
+const (
+	FormatJSONL  = "jsonl"
+	FormatBinary = "binary"
+)
+
+// Formats lists the supported file formats
+var Formats = []string{FormatJSONL, FormatBinary}
+
+var errUnknownFormat = fmt.Errorf("unknown file format, possible values: %s", strings.Join(Formats, ", "))
+
+// ErrCorrupted is returned when a file cannot be decoded
+var ErrCorrupted = errors.New("corrupted archive file")
+
+// RecordWriter encodes records into a stream
+type RecordWriter interface {
+	Write(r Record) error
+}
+
+// RecordReader decodes records from a stream, it returns io.EOF once every record has been read
+type RecordReader interface {
+	Read() (Record, error)
+}
+
+// NewRecordWriter returns a writer encoding the records in the given format
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatBinary:
+		return newBinaryWriter(w)
+	}
+	return nil, errUnknownFormat
+}
+
+// NewRecordReader returns a reader decoding the records in the given format
+func NewRecordReader(r io.Reader, format string) (RecordReader, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	case FormatBinary:
+		return newBinaryReader(r)
+	}
+	return nil, errUnknownFormat
+}
+
+// FromConsumerMessage converts a consumed message into a record
+func FromConsumerMessage(msg *sarama.ConsumerMessage) Record {
+	r := Record{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Key:       msg.Key,
+		Value:     msg.Value,
+	}
+	for _, h := range msg.Headers {
+		if h != nil {
+			r.Headers = append(r.Headers, Header{Key: h.Key, Value: h.Value})
+		}
+	}
+	return r
+}
+
+// ConsumerMessage converts the record back into a consumed message of the given topic,
+// so that it can go through the same processing as the messages consumed from kafka.
+func (r Record) ConsumerMessage(topic string) *sarama.ConsumerMessage {
+	msg := &sarama.ConsumerMessage{
+		Topic:     topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       r.Key,
+		Value:     r.Value,
+	}
+	for _, h := range r.Headers {
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return msg
+}
+
+// timestampMillis converts a timestamp to milliseconds since epoch, the kafka precision.
+// The zero time is encoded as -1, as kafka does for records without timestamp.
+func timestampMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return -1
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms < 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
@@ -0,0 +1,177 @@
+//+build unit
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+var records = []Record{
+	{
+		Partition: 0,
+		Offset:    42,
+		Timestamp: time.Unix(1500000000, 123000000),
+		Key:       []byte("foo"),
+		Value:     []byte(`{"foo":"bar"}`),
+		Headers:   []Header{{Key: []byte("type"), Value: []byte("created")}, {Key: []byte("binary"), Value: []byte{0xff, 0x00}}},
+	},
+	{
+		Partition: 3,
+		Offset:    0,
+		Key:       []byte{0xff, 0xfe, 0x00},
+		Value:     nil,
+	},
+	{
+		Partition: 1,
+		Offset:    1 << 40,
+		Timestamp: time.Unix(0, 0),
+		Key:       nil,
+		Value:     []byte{},
+	},
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		for _, compression := range Compressions {
+			//Arrange
+			var buf bytes.Buffer
+			compressor, err := compress(&buf, compression)
+			assert.NoError(t, err)
+			writer, err := NewRecordWriter(compressor, format)
+			assert.NoError(t, err)
+
+			//Act
+			for _, r := range records {
+				assert.NoError(t, writer.Write(r))
+			}
+			assert.NoError(t, compressor.Close())
+
+			decompressor, err := decompress(&buf, compression)
+			assert.NoError(t, err)
+			reader, err := NewRecordReader(decompressor, format)
+			assert.NoError(t, err)
+			var actual []Record
+			for {
+				r, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err, format+"/"+compression)
+				if err != nil {
+					break
+				}
+				actual = append(actual, r)
+			}
+
+			//Assert
+			assert.Len(t, actual, len(records), format+"/"+compression)
+			for i := range actual {
+				assert.Equal(t, records[i].Partition, actual[i].Partition)
+				assert.Equal(t, records[i].Offset, actual[i].Offset)
+				assert.True(t, records[i].Timestamp.Equal(actual[i].Timestamp), format+"/"+compression)
+				assert.Equal(t, records[i].Key, actual[i].Key, format+"/"+compression)
+				assert.Equal(t, records[i].Value, actual[i].Value, format+"/"+compression)
+				assert.Equal(t, records[i].Headers, actual[i].Headers, format+"/"+compression)
+			}
+		}
+	}
+}
+
+func TestJSONLHandWrittenFixture(t *testing.T) {
+	//Arrange
+	fixture := `{"key":"foo","value":"{\"id\":1}"}
+
+{"partition":2,"valueBase64":"/w==","headers":[{"key":"type","value":"created"}]}
+`
+	reader := newJSONLReader(strings.NewReader(fixture))
+
+	//Act
+	first, firstErr := reader.Read()
+	second, secondErr := reader.Read()
+	_, eofErr := reader.Read()
+
+	//Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, Record{Key: []byte("foo"), Value: []byte(`{"id":1}`)}, first)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, Record{Partition: 2, Value: []byte{0xff}, Headers: []Header{{Key: []byte("type"), Value: []byte("created")}}}, second)
+	assert.Equal(t, io.EOF, eofErr)
+}
+
+func TestJSONLCorrupted(t *testing.T) {
+	//Arrange
+	reader := newJSONLReader(strings.NewReader("{\"key\":\"foo\"}\nnot json\n"))
+
+	//Act
+	_, firstErr := reader.Read()
+	_, secondErr := reader.Read()
+
+	//Assert
+	assert.NoError(t, firstErr)
+	assert.Error(t, secondErr)
+	assert.Contains(t, secondErr.Error(), "line 2")
+}
+
+func TestBinaryCorrupted(t *testing.T) {
+	//Arrange
+	var buf bytes.Buffer
+	writer, _ := newBinaryWriter(&buf)
+	writer.Write(records[0])
+	b := buf.Bytes()
+	b[len(b)-6] ^= 0xff
+
+	//Act
+	_, headerErr := newBinaryReader(strings.NewReader("NOTSEG\x01"))
+	reader, err := newBinaryReader(bytes.NewReader(b))
+	assert.NoError(t, err)
+	_, checksumErr := reader.Read()
+	reader, _ = newBinaryReader(bytes.NewReader(b[:len(b)-2]))
+	_, truncatedErr := reader.Read()
+
+	//Assert
+	assert.Error(t, headerErr)
+	assert.Contains(t, checksumErr.Error(), "checksum")
+	assert.Contains(t, truncatedErr.Error(), "truncated")
+}
+
+func TestConsumerMessageConversion(t *testing.T) {
+	//Arrange
+	msg := &sarama.ConsumerMessage{
+		Topic:     "foo",
+		Partition: 1,
+		Offset:    2,
+		Timestamp: time.Unix(1500000000, 0),
+		Key:       []byte("key"),
+		Value:     []byte("value"),
+		Headers:   []*sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}},
+	}
+
+	//Act
+	actual := FromConsumerMessage(msg).ConsumerMessage("foo")
+
+	//Assert
+	assert.Equal(t, msg, actual)
+}
+
+func TestParseExtension(t *testing.T) {
+	for _, format := range Formats {
+		for _, compression := range Compressions {
+			//Act
+			actualFormat, actualCompression, ok := ParseExtension("foo-0-42" + Extension(format, compression))
+
+			//Assert
+			assert.True(t, ok)
+			assert.Equal(t, format, actualFormat)
+			assert.Equal(t, compression, actualCompression)
+		}
+	}
+	_, _, ok := ParseExtension("foo.txt")
+	assert.False(t, ok)
+}
@@ -0,0 +1,391 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinPartSize is the smallest part size accepted by S3 for multipart uploads, the last part excepted
+const MinPartSize = 5 * 1024 * 1024
+
+var (
+	errInvalidS3URL   = errors.New("invalid S3 location, expected s3://bucket/prefix")
+	errS3PartTooSmall = fmt.Errorf("S3 part size must be at least %d bytes", MinPartSize)
+)
+
+// S3Config is how to reach an S3-compatible object storage
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000 for MinIO
+	Endpoint string
+	Region   string
+	// AccessKey, SecretKey and SessionToken default to the AWS_* environment variables
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// PartSize is the size of the parts of multipart uploads, files smaller than that are uploaded at once
+	PartSize int
+}
+
+// S3 stores the archive files in a bucket of an S3-compatible object storage, under a prefix.
+// Buckets are addressed in path style, which every S3 implementation supports.
+type S3 struct {
+	config S3Config
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+// IsS3URL tells whether a location is an S3 URL rather than a local path
+func IsS3URL(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// NewS3 returns the storage for an s3://bucket/prefix location
+func NewS3(location string, config S3Config) (*S3, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, errInvalidS3URL
+	}
+	if config.PartSize == 0 {
+		config.PartSize = MinPartSize
+	}
+	if config.PartSize < MinPartSize {
+		return nil, errS3PartTooSmall
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	if config.AccessKey == "" {
+		config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		config.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{config: config, bucket: u.Host, prefix: prefix, client: http.DefaultClient}, nil
+}
+
+// Create implements Storage. The file is uploaded in parts as it is written, and completed when closed.
+func (s *S3) Create(name string) (io.WriteCloser, error) {
+	return &s3Upload{s3: s, key: s.prefix + name}, nil
+}
+
+// List implements Source, returning the objects under the prefix
+func (s *S3) List() ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, s.prefix)
+			// Objects in "sub-directories" do not belong to this export
+			if name != "" && !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Open implements Source
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, s.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed request, turning the error responses into errors
+func (s *S3) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := s.config.Endpoint + "/" + s.bucket
+	if key != "" {
+		u += "/" + uriEncode(key, false)
+	}
+	if len(query) > 0 {
+		u += "?" + canonicalQuery(query)
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	signV4(req, body, s.config, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var e struct {
+			Code    string
+			Message string
+		}
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(b, &e) != nil || e.Code == "" {
+			return nil, fmt.Errorf("s3: %s %s: %s", method, key, resp.Status)
+		}
+		return nil, fmt.Errorf("s3: %s %s: %s: %s", method, key, e.Code, e.Message)
+	}
+	return resp, nil
+}
+
+// s3Upload buffers a part and uploads it once full. The multipart upload is only started when
+// a first part is full, smaller files being uploaded with a single request when closed.
+type s3Upload struct {
+	s3       *S3
+	key      string
+	buf      bytes.Buffer
+	uploadID string
+	etags    []string
+	err      error
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	n, _ := u.buf.Write(p)
+	for u.buf.Len() >= u.s3.config.PartSize {
+		if u.err = u.uploadPart(u.buf.Next(u.s3.config.PartSize)); u.err != nil {
+			u.abort()
+			return n, u.err
+		}
+	}
+	return n, nil
+}
+
+func (u *s3Upload) uploadPart(part []byte) error {
+	if u.uploadID == "" {
+		resp, err := u.s3.do(http.MethodPost, u.key, url.Values{"uploads": {""}}, nil)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		u.uploadID = result.UploadID
+	}
+
+	query := url.Values{"partNumber": {strconv.Itoa(len(u.etags) + 1)}, "uploadId": {u.uploadID}}
+	resp, err := u.s3.do(http.MethodPut, u.key, query, part)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	u.etags = append(u.etags, resp.Header.Get("ETag"))
+	return nil
+}
+
+// Close uploads what is left, and completes the multipart upload
+func (u *s3Upload) Close() error {
+	if u.err != nil {
+		return u.err
+	}
+
+	if u.uploadID == "" {
+		resp, err := u.s3.do(http.MethodPut, u.key, nil, u.buf.Bytes())
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if u.buf.Len() > 0 {
+		if err := u.uploadPart(u.buf.Bytes()); err != nil {
+			u.abort()
+			return err
+		}
+	}
+
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	complete := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, etag := range u.etags {
+		complete.Parts = append(complete.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.s3.do(http.MethodPost, u.key, url.Values{"uploadId": {u.uploadID}}, body)
+	if err != nil {
+		u.abort()
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The completion can fail after a 200 response, with the error in the body
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if xml.NewDecoder(resp.Body).Decode(&result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3: completing %s: %s: %s", u.key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// abort discards the uploaded parts, which would otherwise be billed until the bucket's lifecycle removes them
+func (u *s3Upload) abort() {
+	if u.uploadID == "" {
+		return
+	}
+	if resp, err := u.s3.do(http.MethodDelete, u.key, url.Values{"uploadId": {u.uploadID}}, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// signV4 signs a request with AWS Signature Version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+// Every header already set on the request is signed, along with the host.
+func signV4(req *http.Request, body []byte, config S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretKey), day)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery sorts and encodes the query parameters the way SigV4 expects, which url.Values.Encode does not
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and the slashes of paths
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
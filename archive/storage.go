@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Storage is where the archive files are written to
+type Storage interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+// Dir stores the archive files in a local directory
+type Dir string
+
+// Create implements Storage, creating the directory if needed
+func (d Dir) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(string(d), name))
+}
+
+// List implements Source, returning the regular files of the directory
+func (d Dir) List() ([]string, error) {
+	infos, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			names = append(names, info.Name())
+		}
+	}
+	return names, nil
+}
+
+// Open implements Source
+func (d Dir) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+// File is a source made of a single archive file
+type File string
+
+// List implements Source
+func (f File) List() ([]string, error) {
+	return []string{filepath.Base(string(f))}, nil
+}
+
+// Open implements Source
+func (f File) Open(name string) (io.ReadCloser, error) {
+	return os.Open(string(f))
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+//delivery is the metadata of a produced message: how many times it was sent, the consumed message it was produced for,
+//and the acknowledgement of the consumed message once produced
+type delivery struct {
+	attempts int
+	ack      *sourceAck
+	//sent is set once the message is first sent, so that its retries and copies are not counted again
+	sent bool
+
+	//sourced is set when the partition and offset of the consumed message are known
+	sourced   bool
+	partition int32
+	offset    int64
+}
+
+//sourceAck counts the produced messages of a consumed message which are not acknowledged yet, its processing counting as one
+type sourceAck struct {
+	offset  int64
+	pending int32
+}
+
+func (s *sourceAck) add() {
+	atomic.AddInt32(&s.pending, 1)
+}
+
+func (s *sourceAck) done() {
+	atomic.AddInt32(&s.pending, -1)
+}
+
+func (s *sourceAck) acknowledged() bool {
+	return atomic.LoadInt32(&s.pending) == 0
+}
+
+//acks tracks the consumed messages until the messages produced for them are acknowledged, to know which offsets can be committed.
+//A consumed message is acknowledged once it was filtered out, or once every message produced for it was produced, or handled
+//by the produce error policy. It is safe for concurrent use.
+type acks struct {
+	mu         sync.Mutex
+	partitions map[int32][]*sourceAck
+}
+
+func newAcks() *acks {
+	return &acks{partitions: make(map[int32][]*sourceAck)}
+}
+
+//begin starts tracking a consumed message, its processing must be ended with done. The messages of a partition must begin in order.
+func (a *acks) begin(msgC *sarama.ConsumerMessage) *sourceAck {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ack := &sourceAck{offset: msgC.Offset, pending: 1}
+	pending := a.partitions[msgC.Partition]
+	if n := len(pending); n > 0 && pending[n-1].offset >= msgC.Offset {
+		//The partition is consumed again from its committed offset after a rebalance, the messages tracked so far are consumed again
+		pending = nil
+	}
+	a.partitions[msgC.Partition] = append(pending, ack)
+	return ack
+}
+
+//acknowledged returns the offset of the last consumed message of each partition acknowledged along with every message before it,
+//for the partitions which moved forward since the last call
+func (a *acks) acknowledged() map[int32]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	offsets := make(map[int32]int64)
+	for partition, pending := range a.partitions {
+		i := 0
+		for i < len(pending) && pending[i].acknowledged() {
+			i++
+		}
+		if i == 0 {
+			continue
+		}
+		offsets[partition] = pending[i-1].offset
+		for j := 0; j < i; j++ {
+			pending[j] = nil
+		}
+		a.partitions[partition] = pending[i:]
+	}
+	return offsets
+}
+
+//attach records the consumed message a message is produced for, the message acknowledging it once produced if ack is not nil
+func attach(msg *sarama.ProducerMessage, msgC *sarama.ConsumerMessage, ack *sourceAck) {
+	msg.Metadata = delivery{ack: ack, sourced: true, partition: msgC.Partition, offset: msgC.Offset}
+}
+
+//track counts a message about to be sent as pending for the consumed message it acknowledges, once whatever its retries
+func track(msg *sarama.ProducerMessage) {
+	d, ok := msg.Metadata.(delivery)
+	if !ok || d.ack == nil || d.sent {
+		return
+	}
+	d.sent = true
+	msg.Metadata = d
+	d.ack.add()
+}
+
+//acknowledge acknowledges a message sent once and for all: produced, or handled by the produce error policy
+func acknowledge(msg *sarama.ProducerMessage) {
+	if d, ok := msg.Metadata.(delivery); ok && d.ack != nil && d.sent {
+		d.ack.done()
+	}
+}
@@ -0,0 +1,86 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestAcksAcknowledged(t *testing.T) {
+	//Arrange
+	acked := newAcks()
+	first := acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 10})
+	second := acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 11})
+	third := acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 12})
+	other := acked.begin(&sarama.ConsumerMessage{Partition: 1, Offset: 3})
+
+	msg := &sarama.ProducerMessage{Topic: "foo"}
+	attach(msg, &sarama.ConsumerMessage{}, second)
+	track(msg)
+
+	//Act
+	first.done()
+	second.done()
+	third.done()
+	beforeProduced := acked.acknowledged()
+	acknowledge(msg)
+	afterProduced := acked.acknowledged()
+	other.done()
+	afterOther := acked.acknowledged()
+
+	//Assert
+	assert.Equal(t, beforeProduced, map[int32]int64{0: 10})
+	assert.Equal(t, afterProduced, map[int32]int64{0: 12})
+	assert.Equal(t, afterOther, map[int32]int64{1: 3})
+}
+
+func TestAcksRetriesAndChunks(t *testing.T) {
+	//Arrange
+	acked := newAcks()
+	ack := acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 4})
+	msg := &sarama.ProducerMessage{Topic: "foo"}
+	attach(msg, &sarama.ConsumerMessage{}, ack)
+	chunk := copyMessage(msg)
+
+	//Act
+	track(msg)
+	track(chunk)
+	retry := copyMessage(msg)
+	track(retry)
+	ack.done()
+	acknowledge(msg)
+	sentOnce := acked.acknowledged()
+	acknowledge(chunk)
+
+	//Assert
+	assert.Equal(t, len(sentOnce), 0)
+	assert.Equal(t, acked.acknowledged(), map[int32]int64{0: 4})
+}
+
+func TestAcksConsumedAgain(t *testing.T) {
+	//Arrange
+	acked := newAcks()
+	acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 7})
+
+	//Act
+	again := acked.begin(&sarama.ConsumerMessage{Partition: 0, Offset: 5})
+	again.done()
+
+	//Assert
+	assert.Equal(t, acked.acknowledged(), map[int32]int64{0: 5})
+}
+
+func TestAcksUntracked(t *testing.T) {
+	//Arrange
+	msg := &sarama.ProducerMessage{Topic: "foo"}
+
+	//Act
+	track(msg)
+	acknowledge(msg)
+
+	//Assert
+	assert.Equal(t, msg.Metadata, nil)
+}
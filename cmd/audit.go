@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit --from-brokers [url] --from [topic]",
+	Short: "Check which hasher partitioned the events of a topic",
+	Long: `
+	Audit consumes all the events stored in the topic, and computes the partition each hasher would assign to their key.
+	It reports, per partition, how many events are on the partition picked by each hasher, and infers which hasher produced the topic.
+
+	Events without key are not partitioned by hash, they are counted apart.
+	`,
+	Run: Audit,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+}
+
+//Audit handles the consuming / auditing process
+func Audit(cmd *cobra.Command, args []string) {
+
+	switch true {
+	case params.fromTopic == "":
+		log.Print(errMissingSourceTopic)
+		return
+	case params.fromBrokers == "":
+		log.Print(errMissingSourceBrokers)
+		return
+	}
+
+	fromBrokers, _ := getBrokers()
+
+	partitions, err := kafka.PartitionCount(fromBrokers, params.fromTopic)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	consumer := kafka.NewConsumer(params.fromTopic, fromBrokers, consumerGroup+"-audit")
+	if params.verbose {
+		log.Printf("consumer initialized on %s/%s, %d partitions", fromBrokers, params.fromTopic, partitions)
+	}
+
+	//Try to gracefully shutdown
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	a := newAudit(params.fromTopic, partitions)
+	defer func() {
+		log.Printf("audit:\n\t%s", strings.Join(a.lines(), "\n\t"))
+	}()
+
+	consume(consumer.Messages(), a.add)
+}
+
+//auditCounts counts the events of a partition, and how many of them each hasher would have put there
+type auditCounts struct {
+	records  int
+	nullKeys int
+	placed   map[string]int
+}
+
+type audit struct {
+	partitions   int32
+	partitioners map[string]sarama.Partitioner
+	counts       map[int32]*auditCounts
+	errors       int
+}
+
+func newAudit(topic string, partitions int32) *audit {
+	a := &audit{
+		partitions:   partitions,
+		partitioners: make(map[string]sarama.Partitioner),
+		counts:       make(map[int32]*auditCounts),
+	}
+	for _, hasher := range keyHashers {
+		a.partitioners[hasher] = kafka.NewPartitioner(hasher, topic)
+	}
+	return a
+}
+
+func (a *audit) add(msg *sarama.ConsumerMessage) {
+	c, ok := a.counts[msg.Partition]
+	if !ok {
+		c = &auditCounts{placed: make(map[string]int)}
+		a.counts[msg.Partition] = c
+	}
+	c.records++
+
+	if msg.Key == nil {
+		c.nullKeys++
+		return
+	}
+	for hasher, partitioner := range a.partitioners {
+		partition, err := partitioner.Partition(&sarama.ProducerMessage{Key: sarama.ByteEncoder(msg.Key)}, a.partitions)
+		if err != nil {
+			//The other hashers still count the key, their counts being compared with each other
+			a.errors++
+			continue
+		}
+		if partition == msg.Partition {
+			c.placed[hasher]++
+		}
+	}
+}
+
+//inference names the hasher placing every keyed event on its partition. With a single partition, or
+//keys that happen to be placed the same by every hasher, it cannot tell them apart.
+func (a *audit) inference() string {
+	keyed := 0
+	placed := make(map[string]int)
+	for _, c := range a.counts {
+		keyed += c.records - c.nullKeys
+		for hasher, n := range c.placed {
+			placed[hasher] += n
+		}
+	}
+	if keyed == 0 {
+		return "no keyed events, the hasher cannot be inferred"
+	}
+
+	var matching []string
+	best := keyHashers[0]
+	for _, hasher := range keyHashers {
+		if placed[hasher] == keyed {
+			matching = append(matching, hasher)
+		}
+		if placed[hasher] > placed[best] {
+			best = hasher
+		}
+	}
+
+	switch len(matching) {
+	case 1:
+		return fmt.Sprintf("the topic was partitioned with %s", matching[0])
+	case 0:
+		return fmt.Sprintf("no hasher explains the layout, the closest is %s with %s of the keyed events", best, percent(placed[best], keyed))
+	}
+	return fmt.Sprintf("the layout is consistent with %s, the hasher cannot be inferred", strings.Join(matching, " and "))
+}
+
+func (a *audit) lines() []string {
+	partitions := make([]int, 0, len(a.counts))
+	for p := range a.counts {
+		partitions = append(partitions, int(p))
+	}
+	sort.Ints(partitions)
+
+	var lines []string
+	for _, p := range partitions {
+		c := a.counts[int32(p)]
+		line := fmt.Sprintf("partition %d: %d events, %d without key", p, c.records, c.nullKeys)
+		for _, hasher := range keyHashers {
+			line += fmt.Sprintf(", %s: %d (%s)", hasher, c.placed[hasher], percent(c.placed[hasher], c.records-c.nullKeys))
+		}
+		lines = append(lines, line)
+	}
+	if a.errors > 0 {
+		lines = append(lines, fmt.Sprintf("partitioning errors: %d", a.errors))
+	}
+	return append(lines, a.inference())
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
@@ -0,0 +1,138 @@
+//+build unit
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+type auditTest struct {
+	partitions int32
+	messages   []*sarama.ConsumerMessage
+	expected   []string
+}
+
+//With 3 partitions, murmur2 places foo on 2, foobar on 0 and 42 on 1, FNV-1a places them on 1, 0 and 2, crc32 places them all on 2
+var auditTestCases = []auditTest{
+	{
+		partitions: 3,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 2, Key: []byte("foo")},
+			{Partition: 2, Key: []byte("foobar")},
+			{Partition: 2, Key: []byte("42")},
+		},
+		expected: []string{
+			"partition 2: 3 events, 0 without key, murmur2: 1 (33.3%), FNV-1a: 1 (33.3%), crc32: 3 (100.0%)",
+			"the topic was partitioned with crc32",
+		},
+	},
+	{
+		partitions: 3,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 2, Key: []byte("foo")},
+			{Partition: 0, Key: []byte("foobar")},
+			{Partition: 1, Key: []byte("42")},
+			{Partition: 1},
+		},
+		expected: []string{
+			"partition 0: 1 events, 0 without key, murmur2: 1 (100.0%), FNV-1a: 1 (100.0%), crc32: 0 (0.0%)",
+			"partition 1: 2 events, 1 without key, murmur2: 1 (100.0%), FNV-1a: 0 (0.0%), crc32: 0 (0.0%)",
+			"partition 2: 1 events, 0 without key, murmur2: 1 (100.0%), FNV-1a: 0 (0.0%), crc32: 1 (100.0%)",
+			"the topic was partitioned with murmur2",
+		},
+	},
+	{
+		partitions: 3,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 1, Key: []byte("foo")},
+			{Partition: 0, Key: []byte("foobar")},
+			{Partition: 2, Key: []byte("42")},
+		},
+		expected: []string{
+			"partition 0: 1 events, 0 without key, murmur2: 1 (100.0%), FNV-1a: 1 (100.0%), crc32: 0 (0.0%)",
+			"partition 1: 1 events, 0 without key, murmur2: 0 (0.0%), FNV-1a: 1 (100.0%), crc32: 0 (0.0%)",
+			"partition 2: 1 events, 0 without key, murmur2: 0 (0.0%), FNV-1a: 1 (100.0%), crc32: 1 (100.0%)",
+			"the topic was partitioned with FNV-1a",
+		},
+	},
+	{
+		partitions: 3,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 0, Key: []byte("foo")},
+			{Partition: 0, Key: []byte("foobar")},
+			{Partition: 1, Key: []byte("42")},
+		},
+		expected: []string{
+			"partition 0: 2 events, 0 without key, murmur2: 1 (50.0%), FNV-1a: 1 (50.0%), crc32: 0 (0.0%)",
+			"partition 1: 1 events, 0 without key, murmur2: 1 (100.0%), FNV-1a: 0 (0.0%), crc32: 0 (0.0%)",
+			"no hasher explains the layout, the closest is murmur2 with 66.7% of the keyed events",
+		},
+	},
+	{
+		partitions: 1,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 0, Key: []byte("foo")},
+		},
+		expected: []string{
+			"partition 0: 1 events, 0 without key, murmur2: 1 (100.0%), FNV-1a: 1 (100.0%), crc32: 1 (100.0%)",
+			"the layout is consistent with murmur2 and FNV-1a and crc32, the hasher cannot be inferred",
+		},
+	},
+	{
+		partitions: 2,
+		messages: []*sarama.ConsumerMessage{
+			{Partition: 1},
+		},
+		expected: []string{
+			"partition 1: 1 events, 1 without key, murmur2: 0 (-), FNV-1a: 0 (-), crc32: 0 (-)",
+			"no keyed events, the hasher cannot be inferred",
+		},
+	},
+}
+
+func TestAudit(t *testing.T) {
+	for _, v := range auditTestCases {
+		//Arrange
+		a := newAudit("foo", v.partitions)
+
+		//Act
+		for _, msg := range v.messages {
+			a.add(msg)
+		}
+
+		//Assert
+		assert.Equal(t, a.lines(), v.expected)
+	}
+}
+
+type failingPartitioner struct{}
+
+func (failingPartitioner) Partition(*sarama.ProducerMessage, int32) (int32, error) {
+	return 0, errors.New("failing")
+}
+
+func (failingPartitioner) RequiresConsistency() bool {
+	return true
+}
+
+func TestAuditPartitionerError(t *testing.T) {
+	//Arrange
+	a := newAudit("foo", 3)
+	a.partitioners["FNV-1a"] = failingPartitioner{}
+
+	//Act
+	for _, key := range []string{"foo", "foobar", "42"} {
+		a.add(&sarama.ConsumerMessage{Partition: 2, Key: []byte(key)})
+	}
+
+	//Assert
+	assert.Equal(t, a.lines(), []string{
+		"partition 2: 3 events, 0 without key, murmur2: 1 (33.3%), FNV-1a: 0 (0.0%), crc32: 3 (100.0%)",
+		"partitioning errors: 3",
+		"the topic was partitioned with crc32",
+	})
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//compactionBuckets is the number of files the keys are spread over when indexed, a single bucket being loaded in memory at once
+const compactionBuckets = 64
+
+//compactionEntrySize is the size of an index entry without its key: partition, offset and key length
+const compactionEntrySize = 4 + 8 + 4
+
+var (
+	errTombstonesWithoutCompaction = errors.New("tombstones can only be dropped when compacting")
+	errIncompleteIndex             = errors.New("indexing stopped before reaching every message present when the cloning started")
+)
+
+//compaction keeps the latest message of every key of each partition, among the messages present when the cloning starts,
+//the way the log cleaner of a compacted topic would. The keys are first indexed into bucket files on disk, each bucket being
+//then loaded on its own to find the latest offset of its keys: the memory used is the one of a bucket, plus a bit per offset.
+//The messages without key are always kept. It is not safe for concurrent use.
+type compaction struct {
+	dropTombstones bool
+
+	dir     string
+	files   []*os.File
+	writers []*bufio.Writer
+	//err is the first error met while writing the buckets, returned once indexing is over
+	err error
+
+	//latest holds a bit per offset of each partition from its low watermark, set when its message is the latest of its key
+	lows    map[int32]int64
+	latest  map[int32][]uint64
+	indexed int
+	keys    int
+
+	superseded int
+	tombstones int
+}
+
+//buildCompaction indexes the messages present in the source topic, given its watermarks, nil if compaction is not enabled
+func (p parameters) buildCompaction(fromBrokers []string, marks map[int32]kafka.Watermarks) (*compaction, error) {
+	if !p.compact {
+		return nil, nil
+	}
+
+	c, err := newCompaction(p.compactionDir, marks, p.dropTombstones)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("indexing the keys of the %d messages of %s in %s", remaining(marks), p.fromTopic, c.dir)
+	bound := newLoopBound(marks)
+	stopWatching, err := bound.watchTopic(fromBrokers, p.fromTopic)
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	consumer := kafka.NewConsumer(p.fromTopic, fromBrokers, consumerGroup+"-compaction")
+	err = c.index(consumer.Messages(), bound, p.batchSize)
+	stopWatching()
+	if closeErr := consumer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	log.Printf("%d messages indexed, keeping the latest message of %d keys", c.indexed, c.keys)
+	return c, nil
+}
+
+//newCompaction creates the buckets of the index in a temporary directory of dir, the default temporary directory if empty
+func newCompaction(dir string, marks map[int32]kafka.Watermarks, dropTombstones bool) (*compaction, error) {
+	tmp, err := ioutil.TempDir(dir, "kafka-topic-cloner-compaction")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compaction{
+		dropTombstones: dropTombstones,
+		dir:            tmp,
+		lows:           make(map[int32]int64, len(marks)),
+		latest:         make(map[int32][]uint64, len(marks)),
+	}
+	for p, m := range marks {
+		if m.Empty() {
+			continue
+		}
+		c.lows[p] = m.Low
+		c.latest[p] = make([]uint64, (m.High-m.Low+63)/64)
+	}
+	for i := 0; i < compactionBuckets; i++ {
+		file, err := os.Create(filepath.Join(tmp, fmt.Sprintf("bucket-%d", i)))
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		c.files = append(c.files, file)
+		c.writers = append(c.writers, bufio.NewWriter(file))
+	}
+	return c, nil
+}
+
+//index adds the messages consumed up to the bound to the index, and builds it once every partition is finished
+func (c *compaction) index(messages <-chan *sarama.ConsumerMessage, bound *loopBound, batchSize int) error {
+	consumeBatches(messages, batchSize, bound.done, func(batch []*sarama.ConsumerMessage) {
+		for _, msgC := range bound.trim(batch) {
+			c.add(msgC)
+		}
+		putBatch(batch)
+	})
+
+	//A partial index would drop the messages it misses
+	select {
+	case <-bound.done:
+	default:
+		return errIncompleteIndex
+	}
+	return c.build()
+}
+
+//add writes the key of a message to its bucket
+func (c *compaction) add(msgC *sarama.ConsumerMessage) {
+	if msgC.Key == nil || c.err != nil {
+		return
+	}
+	c.indexed++
+
+	var entry [compactionEntrySize]byte
+	binary.BigEndian.PutUint32(entry[0:], uint32(msgC.Partition))
+	binary.BigEndian.PutUint64(entry[4:], uint64(msgC.Offset))
+	binary.BigEndian.PutUint32(entry[12:], uint32(len(msgC.Key)))
+
+	w := c.writers[bucketOf(msgC.Key)]
+	if _, err := w.Write(entry[:]); err != nil {
+		c.err = err
+		return
+	}
+	if _, err := w.Write(msgC.Key); err != nil {
+		c.err = err
+	}
+}
+
+//bucketOf returns the bucket of a key
+func bucketOf(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % compactionBuckets)
+}
+
+//build loads the buckets one at a time to mark the latest offset of every key, and removes them
+func (c *compaction) build() error {
+	if c.err != nil {
+		return c.err
+	}
+	for i, file := range c.files {
+		if err := c.writers[i].Flush(); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		//The keys are prefixed with their partition, compaction applying to each partition on its own
+		latest := make(map[string]int64)
+		r := bufio.NewReader(file)
+		var entry [compactionEntrySize]byte
+		for {
+			if _, err := io.ReadFull(r, entry[:]); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			key := make([]byte, 4+binary.BigEndian.Uint32(entry[12:]))
+			copy(key, entry[:4])
+			if _, err := io.ReadFull(r, key[4:]); err != nil {
+				return err
+			}
+			offset := int64(binary.BigEndian.Uint64(entry[4:]))
+			if last, ok := latest[string(key)]; !ok || offset > last {
+				latest[string(key)] = offset
+			}
+		}
+
+		for key, offset := range latest {
+			c.mark(int32(binary.BigEndian.Uint32([]byte(key[:4]))), offset)
+		}
+		c.keys += len(latest)
+	}
+	return c.close()
+}
+
+func (c *compaction) mark(partition int32, offset int64) {
+	bits, i, ok := c.bit(partition, offset)
+	if ok {
+		bits[i/64] |= 1 << uint(i%64)
+	}
+}
+
+func (c *compaction) isLatest(partition int32, offset int64) bool {
+	bits, i, ok := c.bit(partition, offset)
+	return ok && bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+//bit returns the bits of a partition and the index of the bit of an offset, false if the offset is out of the index
+func (c *compaction) bit(partition int32, offset int64) ([]uint64, int64, bool) {
+	bits, ok := c.latest[partition]
+	i := offset - c.lows[partition]
+	if !ok || i < 0 || i >= int64(len(bits))*64 {
+		return nil, 0, false
+	}
+	return bits, i, true
+}
+
+//trim removes the superseded messages from the batch, and the tombstones if they are dropped, in place, and returns the messages to clone
+func (c *compaction) trim(batch []*sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	kept := batch[:0]
+	for _, msgC := range batch {
+		switch {
+		case msgC.Key == nil:
+		case !c.isLatest(msgC.Partition, msgC.Offset):
+			c.superseded++
+			continue
+		case msgC.Value == nil && c.dropTombstones:
+			c.tombstones++
+			continue
+		}
+		kept = append(kept, msgC)
+	}
+	for i := len(kept); i < len(batch); i++ {
+		batch[i] = nil
+	}
+	return kept
+}
+
+//close removes the buckets, once the index is built or abandoned
+func (c *compaction) close() error {
+	for _, file := range c.files {
+		file.Close()
+	}
+	c.files, c.writers = nil, nil
+	return os.RemoveAll(c.dir)
+}
+
+//count adds the messages left out by the compaction to the summary
+func (c *compaction) count(s *summary) {
+	s.superseded += c.superseded
+	s.tombstonesDropped += c.tombstones
+}
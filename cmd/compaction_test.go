@@ -0,0 +1,120 @@
+//+build unit
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+func TestCompaction(t *testing.T) {
+	consumed := []*sarama.ConsumerMessage{
+		{Partition: 0, Offset: 10, Key: []byte("foo"), Value: []byte("1")},
+		{Partition: 0, Offset: 11, Key: []byte("bar"), Value: []byte("2")},
+		{Partition: 0, Offset: 12, Key: []byte("foo"), Value: []byte("3")},
+		{Partition: 0, Offset: 13, Value: []byte("4")},
+		{Partition: 0, Offset: 14, Key: []byte("bar")},
+		{Partition: 1, Offset: 0, Key: []byte("foo"), Value: []byte("5")},
+		{Partition: 1, Offset: 1, Key: []byte(""), Value: []byte("6")},
+		{Partition: 1, Offset: 2, Key: []byte(""), Value: []byte("7")},
+	}
+
+	tests := []struct {
+		name           string
+		dropTombstones bool
+		expected       []int64
+		superseded     int
+		tombstones     int
+	}{
+		{name: "keeping tombstones", expected: []int64{12, 13, 14, 0, 2}, superseded: 3},
+		{name: "dropping tombstones", dropTombstones: true, expected: []int64{12, 13, 0, 2}, superseded: 3, tombstones: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Arrange
+			dir, err := ioutil.TempDir("", "compaction")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			c, err := newCompaction(dir, map[int32]kafka.Watermarks{0: {Low: 10, High: 15}, 1: {Low: 0, High: 3}}, tt.dropTombstones)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, msgC := range consumed {
+				c.add(msgC)
+			}
+			batch := append([]*sarama.ConsumerMessage(nil), consumed...)
+
+			//Act
+			err = c.build()
+			kept := c.trim(batch)
+
+			//Assert
+			assert.Equal(t, err, nil)
+			offsets := []int64{}
+			for _, msgC := range kept {
+				offsets = append(offsets, msgC.Offset)
+			}
+			assert.Equal(t, offsets, tt.expected)
+			assert.Equal(t, c.superseded, tt.superseded)
+			assert.Equal(t, c.tombstones, tt.tombstones)
+			assert.Equal(t, c.keys, 4)
+			files, _ := ioutil.ReadDir(dir)
+			assert.Equal(t, len(files), 0)
+		})
+	}
+}
+
+func TestCompactionIncompleteIndex(t *testing.T) {
+	//Arrange
+	defer func(timeout int) { params.timeout = timeout }(params.timeout)
+	params.timeout = 10
+	c, err := newCompaction("", map[int32]kafka.Watermarks{0: {Low: 0, High: 2}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	messages := make(chan *sarama.ConsumerMessage, 1)
+	messages <- &sarama.ConsumerMessage{Partition: 0, Offset: 0, Key: []byte("foo")}
+
+	//Act
+	err = c.index(messages, newLoopBound(map[int32]kafka.Watermarks{0: {Low: 0, High: 2}}), 10)
+
+	//Assert
+	assert.Equal(t, err, errIncompleteIndex)
+}
+
+func TestCompactionIndexGap(t *testing.T) {
+	//Arrange
+	defer func(timeout int) { params.timeout = timeout }(params.timeout)
+	params.timeout = 1000
+	marks := map[int32]kafka.Watermarks{0: {Low: 0, High: 3}}
+	c, err := newCompaction("", marks, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	//The last offset holds no message, a transaction marker for instance
+	messages := make(chan *sarama.ConsumerMessage, 2)
+	messages <- &sarama.ConsumerMessage{Partition: 0, Offset: 0, Key: []byte("foo")}
+	messages <- &sarama.ConsumerMessage{Partition: 0, Offset: 1, Key: []byte("foo")}
+	bound := newLoopBound(marks)
+	stop := bound.watch(time.Millisecond, func(int32, int64, int64) (bool, error) { return false, nil })
+	defer stop()
+
+	//Act
+	err = c.index(messages, bound, 10)
+
+	//Assert
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.isLatest(0, 0), false)
+	assert.Equal(t, c.isLatest(0, 1), true)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+//consume calls handle with every consumed message, until the timeout expires without any new message, or the application is interrupted.
+//A timeout of 0 never expires.
+func consume(messages <-chan *sarama.ConsumerMessage, handle func(*sarama.ConsumerMessage)) {
+	consumeUntil(messages, nil, handle)
+}
+
+//consumeUntil is consume, stopping as well once done is closed. A nil done is never closed.
+func consumeUntil(messages <-chan *sarama.ConsumerMessage, done <-chan struct{}, handle func(*sarama.ConsumerMessage)) {
+	consumeBoth(messages, nil, done, func(_ int, msgC *sarama.ConsumerMessage) {
+		handle(msgC)
+	})
+}
+
+//Sides of consumeBoth
+const (
+	sourceSide = 0
+	targetSide = 1
+)
+
+//consumeBoth is consumeUntil for two topics at once, handle being told which side each message comes from.
+//The timeout only expires when neither topic has a new message.
+func consumeBoth(source, target <-chan *sarama.ConsumerMessage, done <-chan struct{}, handle func(side int, msgC *sarama.ConsumerMessage)) {
+	//Capture interrupt and kill signal to stop the application
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, os.Kill)
+	defer signal.Stop(signals)
+
+	//A nil channel is never ready, which disables its case once closed
+	channels := [2]<-chan *sarama.ConsumerMessage{source, target}
+
+Loop:
+	for {
+		var msgC *sarama.ConsumerMessage
+		var ok bool
+		side := sourceSide
+
+		select {
+
+		case msgC, ok = <-channels[sourceSide]:
+
+		case msgC, ok = <-channels[targetSide]:
+			side = targetSide
+
+		case <-signals:
+			log.Print("terminating application")
+			break Loop
+
+		case <-done:
+			log.Print("bound reached - end of cloning")
+			break Loop
+
+		case <-idle():
+			log.Print("timeout - end of cloning")
+			break Loop
+		}
+
+		if !ok {
+			channels[side] = nil
+			if channels[sourceSide] == nil && channels[targetSide] == nil {
+				break Loop
+			}
+			continue
+		}
+		if params.verbose {
+			log.Print(fmt.Sprintf("message consumed at partition %v, offset %v", msgC.Partition, msgC.Offset))
+		}
+		handle(side, msgC)
+	}
+}
+
+//idle returns a channel receiving once the timeout expires, or a nil channel if the timeout is disabled
+func idle() <-chan time.Time {
+	if params.timeout <= 0 {
+		return nil
+	}
+	return time.After(time.Duration(params.timeout) * time.Millisecond)
+}
+
+//consumeBatches is consume, calling handle with batches of messages rather than one message at a time.
+//A batch holds the messages already waiting in the channel, up to max, so that the messages delivered by a fetch
+//are handled together, while a lone message is not delayed to fill a batch.
+//handle owns the batch it is given, and consuming stops once done is closed.
+func consumeBatches(messages <-chan *sarama.ConsumerMessage, max int, done <-chan struct{}, handle func([]*sarama.ConsumerMessage)) {
+	consumeUntil(messages, done, func(msgC *sarama.ConsumerMessage) {
+		batch := getBatch(max)
+		batch = append(batch, msgC)
+
+	Drain:
+		for len(batch) < max {
+			select {
+			case next, ok := <-messages:
+				if !ok {
+					break Drain
+				}
+				batch = append(batch, next)
+			default:
+				break Drain
+			}
+		}
+		handle(batch)
+	})
+}
+
+var batchPool sync.Pool
+
+//getBatch returns an empty batch, reusing the batches given back with putBatch
+func getBatch(capacity int) []*sarama.ConsumerMessage {
+	if b, ok := batchPool.Get().(*[]*sarama.ConsumerMessage); ok && cap(*b) >= capacity {
+		return (*b)[:0]
+	}
+	return make([]*sarama.ConsumerMessage, 0, capacity)
+}
+
+//putBatch gives back a batch once its messages have been handled
+func putBatch(batch []*sarama.ConsumerMessage) {
+	for i := range batch {
+		batch[i] = nil
+	}
+	batch = batch[:0]
+	batchPool.Put(&batch)
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//maxSampleLength truncates the keys and values of the printed samples
+const maxSampleLength = 200
+
+//dryRunPartition is what would have been produced on a partition
+type dryRunPartition struct {
+	topic     string
+	partition int32
+	records   int
+	bytes     int
+}
+
+//dryRun partitions the messages the way the producer would, and keeps track of what would have been produced.
+//It is safe for concurrent use.
+type dryRun struct {
+	mu             sync.Mutex
+	hasher         string
+	samples        int
+	partitionCount func(topic string) (int32, error)
+	//onAdd is called with every message added, its partition set, if it is not nil
+	onAdd func(*sarama.ProducerMessage)
+
+	partitioners map[string]sarama.Partitioner
+	counts       map[string]int32
+	partitions   map[string]map[int32]*dryRunPartition
+	sampled      []string
+	errors       int
+}
+
+func newDryRun(hasher string, samples int, partitionCount func(topic string) (int32, error)) *dryRun {
+	return &dryRun{
+		hasher:         hasher,
+		samples:        samples,
+		partitionCount: partitionCount,
+		partitioners:   make(map[string]sarama.Partitioner),
+		counts:         make(map[string]int32),
+		partitions:     make(map[string]map[int32]*dryRunPartition),
+	}
+}
+
+func (d *dryRun) add(msg *sarama.ProducerMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	partitioner, ok := d.partitioners[msg.Topic]
+	if !ok {
+		count, err := d.partitionCount(msg.Topic)
+		if err != nil {
+			log.Printf("cannot get the partitions of %s: %v", msg.Topic, err)
+			d.errors++
+			return
+		}
+		partitioner = kafka.NewPartitioner(d.hasher, msg.Topic)
+		d.partitioners[msg.Topic] = partitioner
+		d.counts[msg.Topic] = count
+	}
+
+	partition, err := partitioner.Partition(msg, d.counts[msg.Topic])
+	if err != nil {
+		d.errors++
+		return
+	}
+
+	if d.partitions[msg.Topic] == nil {
+		d.partitions[msg.Topic] = make(map[int32]*dryRunPartition)
+	}
+	p, ok := d.partitions[msg.Topic][partition]
+	if !ok {
+		p = &dryRunPartition{topic: msg.Topic, partition: partition}
+		d.partitions[msg.Topic][partition] = p
+	}
+	p.records++
+	p.bytes += messageSize(msg)
+	if d.onAdd != nil {
+		msg.Partition = partition
+		d.onAdd(msg)
+	}
+
+	if len(d.sampled) < d.samples {
+		d.sampled = append(d.sampled, fmt.Sprintf("%s/%d: key: %s, value: %s", msg.Topic, partition, sample(msg.Key), sample(msg.Value)))
+	}
+}
+
+func sample(e sarama.Encoder) string {
+	if e == nil {
+		return "null"
+	}
+	b, err := e.Encode()
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	if len(b) > maxSampleLength {
+		return fmt.Sprintf("%q...", b[:maxSampleLength])
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+func (d *dryRun) lines() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var partitions []*dryRunPartition
+	for _, byPartition := range d.partitions {
+		for _, p := range byPartition {
+			partitions = append(partitions, p)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].topic != partitions[j].topic {
+			return partitions[i].topic < partitions[j].topic
+		}
+		return partitions[i].partition < partitions[j].partition
+	})
+
+	var lines []string
+	for _, p := range partitions {
+		lines = append(lines, fmt.Sprintf("%s/%d: %d messages, %d bytes", p.topic, p.partition, p.records, p.bytes))
+	}
+	if d.errors > 0 {
+		lines = append(lines, fmt.Sprintf("partitioning errors: %d", d.errors))
+	}
+	for _, s := range d.sampled {
+		lines = append(lines, "sample "+s)
+	}
+	return lines
+}
+
+func (d *dryRun) print() {
+	log.Printf("dry run:\n\t%s", strings.Join(d.lines(), "\n\t"))
+}
@@ -0,0 +1,72 @@
+//+build unit
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+func TestDryRun(t *testing.T) {
+	//Arrange
+	counted := 0
+	dry := newDryRun("murmur2", 2, func(topic string) (int32, error) {
+		counted++
+		if topic == "missing" {
+			return 0, errors.New("unknown topic")
+		}
+		return 3, nil
+	})
+	msgs := []*sarama.ProducerMessage{
+		{Topic: "bar", Key: sarama.StringEncoder("foo"), Value: sarama.StringEncoder("1234")},
+		{Topic: "bar", Key: sarama.StringEncoder("foo"), Value: sarama.StringEncoder("56"), Headers: []sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}}},
+		{Topic: "bar", Key: sarama.StringEncoder("42")},
+		{Topic: "missing", Key: sarama.StringEncoder("42")},
+	}
+
+	//Act
+	for _, msg := range msgs {
+		dry.add(msg)
+	}
+
+	//Assert
+	assert.Equal(t, dry.lines(), []string{
+		"bar/1: 1 messages, 2 bytes",
+		"bar/2: 2 messages, 14 bytes",
+		"partitioning errors: 1",
+		`sample bar/2: key: "foo", value: "1234"`,
+		`sample bar/2: key: "foo", value: "56"`,
+	})
+	assert.Equal(t, counted, 2)
+}
+
+func TestDryRunPreservedPartitions(t *testing.T) {
+	//Arrange
+	dry := newDryRun(kafka.ManualPartitioning, 0, func(string) (int32, error) { return 4, nil })
+
+	//Act
+	dry.add(&sarama.ProducerMessage{Topic: "bar", Partition: 3, Value: sarama.StringEncoder("foo")})
+
+	//Assert
+	assert.Equal(t, dry.lines(), []string{"bar/3: 1 messages, 3 bytes"})
+}
+
+func TestDryRunOnAdd(t *testing.T) {
+	//Arrange
+	var added []int32
+	dry := newDryRun(kafka.ManualPartitioning, 0, func(string) (int32, error) { return 4, nil })
+	dry.onAdd = func(msg *sarama.ProducerMessage) {
+		added = append(added, msg.Partition)
+	}
+
+	//Act
+	dry.add(&sarama.ProducerMessage{Topic: "bar", Partition: 3, Value: sarama.StringEncoder("foo")})
+	dry.add(&sarama.ProducerMessage{Topic: "bar", Partition: 1, Value: sarama.StringEncoder("foo")})
+
+	//Assert
+	assert.Equal(t, added, []int32{3, 1})
+}
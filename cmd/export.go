@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+type exportParameters struct {
+	output          string
+	format          string
+	fileCompression string
+	maxFileSize     int64
+}
+
+var (
+	exportParams exportParameters
+
+	errMissingOutput          = errors.New("output directory or S3 location must be set")
+	errUnknownFormat          = errors.New("unknown file format, see help for possible value")
+	errUnknownFileCompression = errors.New("unknown file compression, see help for possible value")
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export --from-brokers [url] --from [source] --output [directory or s3://bucket/prefix]",
+	Short: "Export the content of a topic to local files or to S3",
+	Long: `
+	Export consumes all the events stored in the source topic, and writes them to files in the output directory.
+	Each event is stored with its key, value, headers, timestamp, partition and offset.
+
+	The files are written either in JSON Lines (one event per line), or in a compact binary format, and can be compressed.
+	Each partition is written to its own files, which are rotated once they reach the maximum file size.
+	A manifest listing the files and the offset ranges of each partition is written along with them.
+
+	The output can also be an S3 location (s3://bucket/prefix) of AWS S3 or of a compatible object storage such as MinIO.
+	The files are uploaded in parts as they are written, the credentials are read from the AWS_ACCESS_KEY_ID,
+	AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+	`,
+	Run: Export,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVarP(&exportParams.output, "output", "d", "", "directory or S3 location (s3://bucket/prefix) where the files are written")
+	exportCmd.Flags().StringVar(&exportParams.format, "format", archive.FormatJSONL, fmt.Sprintf("file format (possible values: %s)", strings.Join(archive.Formats, ", ")))
+	exportCmd.Flags().StringVar(&exportParams.fileCompression, "file-compression", archive.CompressionNone, fmt.Sprintf("file compression (possible values: %s)", strings.Join(archive.Compressions, ", ")))
+	exportCmd.Flags().Int64Var(&exportParams.maxFileSize, "max-file-size", 0, "size (bytes) after which a partition's file is rotated, 0 to disable the rotation")
+	addS3Flags(exportCmd)
+}
+
+//Export handles the consuming / writing process
+func Export(cmd *cobra.Command, args []string) {
+
+	if err := exportParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	storage, err := exportParams.storage()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	exporter, err := archive.NewExporter(storage, params.fromTopic, exportParams.format, exportParams.fileCompression, exportParams.maxFileSize)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	fromBrokers, _ := getBrokers()
+
+	consumer := kafka.NewConsumer(params.fromTopic, fromBrokers, consumerGroup)
+	if params.verbose {
+		log.Printf("consumer (group: %s) initialized on %s/%s", consumerGroup, fromBrokers, params.fromTopic)
+	}
+
+	//Try to gracefully shutdown
+	defer func() {
+		if err := exporter.Close(); err != nil {
+			log.Fatal(err)
+		}
+		if err := consumer.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	exported := 0
+	defer func() {
+		log.Printf("%d messages exported to %s", exported, exportParams.output)
+	}()
+
+	consume(consumer.Messages(), func(msgC *sarama.ConsumerMessage) {
+		if err := exporter.Write(archive.FromConsumerMessage(msgC)); err != nil {
+			log.Fatal(err)
+		}
+		exported++
+	})
+}
+
+func (e exportParameters) storage() (archive.Storage, error) {
+	if archive.IsS3URL(e.output) {
+		return s3Params.newS3(e.output)
+	}
+	return archive.Dir(e.output), nil
+}
+
+func (e exportParameters) validate(p parameters) error {
+	switch true {
+
+	case p.fromTopic == "":
+		return errMissingSourceTopic
+
+	case p.fromBrokers == "":
+		return errMissingSourceBrokers
+
+	case e.output == "":
+		return errMissingOutput
+
+	case !contains(archive.Formats, e.format):
+		return errUnknownFormat
+
+	case !contains(archive.Compressions, e.fileCompression):
+		return errUnknownFileCompression
+
+	}
+	return nil
+}
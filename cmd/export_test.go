@@ -0,0 +1,78 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+)
+
+type exportParametersTest struct {
+	params   exportParameters
+	expected error
+}
+
+var exportParametersTestCases = []exportParametersTest{
+	{
+		params: exportParameters{
+			output:          "backup",
+			format:          "jsonl",
+			fileCompression: "zstd",
+		},
+		expected: nil,
+	},
+	{
+		params: exportParameters{
+			format:          "jsonl",
+			fileCompression: "zstd",
+		},
+		expected: errMissingOutput,
+	},
+	{
+		params: exportParameters{
+			output:          "backup",
+			format:          "csv",
+			fileCompression: "zstd",
+		},
+		expected: errUnknownFormat,
+	},
+	{
+		params: exportParameters{
+			output:          "backup",
+			format:          "binary",
+			fileCompression: "bzip2",
+		},
+		expected: errUnknownFileCompression,
+	},
+}
+
+func TestValidateExportParameters(t *testing.T) {
+	//Arrange
+	p := parameters{
+		fromBrokers: "foo",
+		fromTopic:   "bar",
+	}
+
+	for _, v := range exportParametersTestCases {
+		//Act
+		actual := v.params.validate(p)
+
+		//Assert
+		assert.Equal(t, actual, v.expected)
+	}
+}
+
+func TestExportStorage(t *testing.T) {
+	//Act
+	dir, dirErr := exportParameters{output: "backup"}.storage()
+	_, s3Err := exportParameters{output: "s3://backups/foo"}.storage()
+	_, invalidErr := exportParameters{output: "s3://"}.storage()
+
+	//Assert
+	assert.Equal(t, dir, archive.Storage(archive.Dir("backup")))
+	assert.Equal(t, dirErr, nil)
+	assert.Equal(t, s3Err, nil)
+	assert.Equal(t, invalidErr != nil, true)
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//Possible policies applied to the messages that could not be produced, once the retries are exhausted
+const (
+	failPolicy       = "fail"
+	skipPolicy       = "skip"
+	deadLetterPolicy = "dead-letter"
+)
+
+//Headers added to the dead-lettered messages
+const (
+	deadLetterErrorHeader     = "dead-letter-error"
+	deadLetterTopicHeader     = "dead-letter-topic"
+	deadLetterPartitionHeader = "dead-letter-partition"
+	deadLetterAttemptsHeader  = "dead-letter-attempts"
+	deadLetterTimeHeader      = "dead-letter-time"
+)
+
+//maxBackoffShift caps the exponential backoff at 1024 times the initial delay
+const maxBackoffShift = 10
+
+var (
+	possibleFailurePolicies = []string{failPolicy, skipPolicy, deadLetterPolicy}
+
+	errUnknownFailurePolicy = errors.New("unknown produce error policy, see help for possible value")
+	errMissingDeadLetter    = errors.New("dead-letter topic or file must be set with the dead-letter policy")
+	errInvalidRetries       = errors.New("produce retries and retry backoff must not be negative")
+	errUnorderedRetries     = errors.New("produce retries do not keep the order of the partitions, they cannot be combined with several producers or split events")
+)
+
+//failures handles the messages the producer failed to produce: they are retried with an exponential backoff,
+//then the policy is applied. It is safe for concurrent use.
+//
+//A retried message is sent again after the messages of its partition sent meanwhile: the partitions do not keep their order.
+//The failure is only reported once the producer gave up on the message, while the following messages may already be produced,
+//so holding them back could not restore the order either.
+type failures struct {
+	policy  string
+	retries int
+	backoff time.Duration
+
+	//resend produces a message again, it is set once the sink is built
+	resend func(*sarama.ProducerMessage)
+	//deadLetter stores a failed message along with its error
+	deadLetter func(*sarama.ProducerMessage) error
+	//closeDeadLetter flushes the dead-letter topic or file
+	closeDeadLetter func() error
+
+	sleep func(time.Duration)
+	exit  func(error)
+
+	mu      sync.Mutex
+	closing bool
+	pending sync.WaitGroup
+
+	failed       int64
+	deadLettered int64
+}
+
+func newFailures(policy string, retries int, backoff time.Duration) *failures {
+	return &failures{
+		policy:  policy,
+		retries: retries,
+		backoff: backoff,
+		sleep:   time.Sleep,
+		exit: func(err error) {
+			log.Fatalf("Failed to produce message, stopping: %v", err)
+		},
+	}
+}
+
+//buildFailures returns the failure handling configured by the parameters, writing to the dead-letter topic or file if needed
+func (p parameters) buildFailures(toBrokers []string) (*failures, error) {
+	f := newFailures(p.onProduceError, p.produceRetries, p.retryBackoff)
+	if p.onProduceError != deadLetterPolicy || p.dryRun {
+		return f, nil
+	}
+
+	if p.deadLetterTopic != "" {
+		//The dead letters cannot fail in turn, the run stops if they do
+		producer := kafka.NewProducer(toBrokers, p.hasher, p.compressionType, 0, func(err *sarama.ProducerError) {
+			f.exit(fmt.Errorf("dead-letter topic %s: %v", p.deadLetterTopic, err.Err))
+		}, nil)
+		f.deadLetter = func(msg *sarama.ProducerMessage) error {
+			msg.Topic = p.deadLetterTopic
+			producer.Input() <- msg
+			return nil
+		}
+		f.closeDeadLetter = producer.Close
+		return f, nil
+	}
+
+	file, err := os.Create(p.deadLetterFile)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	writer, err := archive.NewRecordWriter(buf, archive.FormatJSONL)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	f.deadLetter = func(msg *sarama.ProducerMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return writer.Write(deadLetterRecord(msg))
+	}
+	f.closeDeadLetter = func() error {
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	return f, nil
+}
+
+//deadLetterRecord converts a dead-lettered message into a record, it can be produced again with the import command
+func deadLetterRecord(msg *sarama.ProducerMessage) archive.Record {
+	rec := archive.Record{Partition: msg.Partition, Timestamp: msg.Timestamp}
+	if msg.Key != nil {
+		rec.Key, _ = msg.Key.Encode()
+	}
+	if msg.Value != nil {
+		rec.Value, _ = msg.Value.Encode()
+	}
+	for _, h := range msg.Headers {
+		rec.Headers = append(rec.Headers, archive.Header{Key: h.Key, Value: h.Value})
+	}
+	return rec
+}
+
+//handle retries the message, or applies the policy once the retries are exhausted
+func (f *failures) handle(err *sarama.ProducerError) {
+	attempt := attempts(err.Msg)
+
+	f.mu.Lock()
+	retry := attempt <= f.retries && !f.closing
+	if retry {
+		f.pending.Add(1)
+	}
+	f.mu.Unlock()
+
+	if retry {
+		msg := copyMessage(err.Msg)
+		d, _ := msg.Metadata.(delivery)
+		d.attempts = attempt + 1
+		msg.Metadata = d
+		go func() {
+			defer f.pending.Done()
+			f.sleep(f.delay(attempt))
+			f.resend(msg)
+		}()
+		return
+	}
+
+	atomic.AddInt64(&f.failed, 1)
+	switch f.policy {
+	case failPolicy:
+		f.exit(err)
+
+	case deadLetterPolicy:
+		if dlErr := f.deadLetter(withErrorHeaders(err, attempt)); dlErr != nil {
+			f.exit(fmt.Errorf("cannot dead-letter message: %v", dlErr))
+			return
+		}
+		atomic.AddInt64(&f.deadLettered, 1)
+		acknowledge(err.Msg)
+
+	default:
+		log.Printf("Failed to produce message, skipping it: %v", err)
+		acknowledge(err.Msg)
+	}
+}
+
+//delay is the backoff before the given attempt is retried, doubled after each attempt
+func (f *failures) delay(attempt int) time.Duration {
+	shift := uint(attempt - 1)
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	return f.backoff << shift
+}
+
+//attempts returns how many times the message was sent, the count being kept in its metadata
+func attempts(msg *sarama.ProducerMessage) int {
+	if d, ok := msg.Metadata.(delivery); ok && d.attempts > 0 {
+		return d.attempts
+	}
+	return 1
+}
+
+//copyMessage returns a fresh copy of a message, the producer keeping its own state in the messages it handled
+func copyMessage(msg *sarama.ProducerMessage) *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{
+		Topic:     msg.Topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   append([]sarama.RecordHeader(nil), msg.Headers...),
+		Partition: msg.Partition,
+		Timestamp: msg.Timestamp,
+		Metadata:  msg.Metadata,
+	}
+}
+
+//withErrorHeaders returns a copy of the failed message, with headers describing the error
+func withErrorHeaders(err *sarama.ProducerError, attempt int) *sarama.ProducerMessage {
+	msg := copyMessage(err.Msg)
+	msg.Metadata = nil
+	msg.Headers = append(msg.Headers,
+		sarama.RecordHeader{Key: []byte(deadLetterErrorHeader), Value: []byte(err.Err.Error())},
+		sarama.RecordHeader{Key: []byte(deadLetterTopicHeader), Value: []byte(err.Msg.Topic)},
+		sarama.RecordHeader{Key: []byte(deadLetterPartitionHeader), Value: []byte(strconv.Itoa(int(err.Msg.Partition)))},
+		sarama.RecordHeader{Key: []byte(deadLetterAttemptsHeader), Value: []byte(strconv.Itoa(attempt))},
+		sarama.RecordHeader{Key: []byte(deadLetterTimeHeader), Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+	return msg
+}
+
+//drain stops retrying, and waits for the pending retries to be sent. The failures reported afterwards,
+//while the producer is flushed, get the policy applied at once.
+func (f *failures) drain() {
+	f.mu.Lock()
+	f.closing = true
+	f.mu.Unlock()
+	f.pending.Wait()
+}
+
+//close flushes the dead letters, once the producer is closed
+func (f *failures) close() {
+	if f.closeDeadLetter == nil {
+		return
+	}
+	if err := f.closeDeadLetter(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//count adds the failures to the summary
+func (f *failures) count(s *summary) {
+	s.produceFailures += int(atomic.LoadInt64(&f.failed))
+	s.deadLettered += int(atomic.LoadInt64(&f.deadLettered))
+}
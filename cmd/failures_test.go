@@ -0,0 +1,157 @@
+//+build unit
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+var errProduce = errors.New("produce failed")
+
+//newTestFailures returns failures resending synchronously, and recording the resent messages, sleeps and exits
+func newTestFailures(policy string, retries int) (f *failures, resent *[]*sarama.ProducerMessage, sleeps *[]time.Duration, exits *[]error) {
+	resent, sleeps, exits = &[]*sarama.ProducerMessage{}, &[]time.Duration{}, &[]error{}
+	f = newFailures(policy, retries, time.Second)
+	f.sleep = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+	f.exit = func(err error) { *exits = append(*exits, err) }
+	f.resend = func(msg *sarama.ProducerMessage) { *resent = append(*resent, msg) }
+	return
+}
+
+func TestFailuresRetry(t *testing.T) {
+	//Arrange
+	f, resent, sleeps, _ := newTestFailures(skipPolicy, 2)
+	msg := &sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder("bar")}
+
+	//Act
+	for i := 0; i < 3; i++ {
+		f.handle(&sarama.ProducerError{Msg: msg, Err: errProduce})
+		f.pending.Wait()
+		if len(*resent) > 0 {
+			msg = (*resent)[len(*resent)-1]
+		}
+	}
+	stats := newSummary()
+	f.count(stats)
+
+	//Assert
+	assert.Equal(t, len(*resent), 2)
+	assert.Equal(t, *sleeps, []time.Duration{time.Second, 2 * time.Second})
+	assert.Equal(t, attempts(msg), 3)
+	assert.Equal(t, stats.produceFailures, 1)
+	assert.Equal(t, stats.deadLettered, 0)
+}
+
+func TestFailuresNoRetryWhenClosing(t *testing.T) {
+	//Arrange
+	f, resent, _, _ := newTestFailures(skipPolicy, 2)
+	f.drain()
+
+	//Act
+	f.handle(&sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "foo"}, Err: errProduce})
+
+	//Assert
+	assert.Equal(t, len(*resent), 0)
+	assert.Equal(t, f.failed, int64(1))
+}
+
+func TestFailuresFail(t *testing.T) {
+	//Arrange
+	f, _, _, exits := newTestFailures(failPolicy, 0)
+
+	//Act
+	f.handle(&sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "foo"}, Err: errProduce})
+
+	//Assert
+	assert.Equal(t, len(*exits), 1)
+	assert.Equal(t, f.failed, int64(1))
+}
+
+func TestFailuresDeadLetter(t *testing.T) {
+	//Arrange
+	f, _, _, exits := newTestFailures(deadLetterPolicy, 0)
+	var dead []*sarama.ProducerMessage
+	f.deadLetter = func(msg *sarama.ProducerMessage) error {
+		dead = append(dead, msg)
+		return nil
+	}
+	msg := &sarama.ProducerMessage{
+		Topic:     "foo",
+		Partition: 3,
+		Key:       sarama.StringEncoder("bar"),
+		Headers:   []sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("42")}},
+	}
+
+	//Act
+	f.handle(&sarama.ProducerError{Msg: msg, Err: errProduce})
+	stats := newSummary()
+	f.count(stats)
+
+	//Assert
+	assert.Equal(t, len(*exits), 0)
+	assert.Equal(t, stats.produceFailures, 1)
+	assert.Equal(t, stats.deadLettered, 1)
+	assert.Equal(t, len(dead), 1)
+	headers := make(map[string]string)
+	for _, h := range dead[0].Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, headers["trace"], "42")
+	assert.Equal(t, headers[deadLetterErrorHeader], "produce failed")
+	assert.Equal(t, headers[deadLetterTopicHeader], "foo")
+	assert.Equal(t, headers[deadLetterPartitionHeader], "3")
+	assert.Equal(t, headers[deadLetterAttemptsHeader], "1")
+	assert.Equal(t, len(msg.Headers), 1)
+}
+
+func TestFailuresDeadLetterError(t *testing.T) {
+	//Arrange
+	f, _, _, exits := newTestFailures(deadLetterPolicy, 0)
+	f.deadLetter = func(msg *sarama.ProducerMessage) error {
+		return errors.New("disk full")
+	}
+
+	//Act
+	f.handle(&sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "foo"}, Err: errProduce})
+
+	//Assert
+	assert.Equal(t, len(*exits), 1)
+	assert.Equal(t, strings.Contains((*exits)[0].Error(), "disk full"), true)
+	assert.Equal(t, f.deadLettered, int64(0))
+}
+
+func TestFailuresDelay(t *testing.T) {
+	f := newFailures(skipPolicy, 20, 100*time.Millisecond)
+
+	assert.Equal(t, f.delay(1), 100*time.Millisecond)
+	assert.Equal(t, f.delay(3), 400*time.Millisecond)
+	assert.Equal(t, f.delay(20), 1024*100*time.Millisecond)
+}
+
+func TestDeadLetterRecord(t *testing.T) {
+	//Arrange
+	ts := time.Unix(1500000000, 0)
+	msg := &sarama.ProducerMessage{
+		Partition: 2,
+		Timestamp: ts,
+		Key:       sarama.StringEncoder("foo"),
+		Value:     sarama.ByteEncoder("bar"),
+		Headers:   []sarama.RecordHeader{{Key: []byte(deadLetterErrorHeader), Value: []byte("produce failed")}},
+	}
+
+	//Act
+	rec := deadLetterRecord(msg)
+
+	//Assert
+	assert.Equal(t, rec.Partition, int32(2))
+	assert.Equal(t, rec.Timestamp, ts)
+	assert.Equal(t, string(rec.Key), "foo")
+	assert.Equal(t, string(rec.Value), "bar")
+	assert.Equal(t, string(rec.Headers[0].Value), "produce failed")
+}
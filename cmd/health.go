@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+var (
+	errNotJoined    = errors.New("the consumer does not own its partitions yet, its group is rebalancing")
+	errShuttingDown = errors.New("shutting down")
+)
+
+//health is the state reported by the health endpoints of the mirror. It is safe for concurrent use.
+type health struct {
+	mu       sync.Mutex
+	joined   bool
+	failing  error
+	stopping bool
+}
+
+func newHealth() *health {
+	return &health{}
+}
+
+//rebalanced records the end of a rebalance, the consumer owning its partitions
+func (h *health) rebalanced() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.joined = true
+	h.failing = nil
+}
+
+//rebalancing records the start of a rebalance
+func (h *health) rebalancing() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.joined = false
+}
+
+//failed records an error, until the next success
+func (h *health) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failing = err
+}
+
+//succeeded records a success: a message consumed or offsets committed
+func (h *health) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failing = nil
+}
+
+func (h *health) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopping = true
+}
+
+//ready returns why the mirror is not ready, or nil if it is
+func (h *health) ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch true {
+
+	case h.stopping:
+		return errShuttingDown
+
+	case h.failing != nil:
+		return h.failing
+
+	case !h.joined:
+		return errNotJoined
+
+	}
+	return nil
+}
+
+//handler serves /healthz, answering as long as the mirror runs, and /readyz, answering once the consumer owns its partitions
+//and until an error occurs without being followed by a success
+func (h *health) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+	return mux
+}
+
+//serve starts serving the health endpoints on addr
+func (h *health) serve(addr string) {
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, h.handler()))
+	}()
+}
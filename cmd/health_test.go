@@ -0,0 +1,54 @@
+//+build unit
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+//get returns the status code of a request to the health endpoints
+func get(h *health, path string) int {
+	rec := httptest.NewRecorder()
+	h.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	//Arrange
+	h := newHealth()
+
+	//Act
+	starting := get(h, "/readyz")
+	h.rebalanced()
+	joined := get(h, "/readyz")
+	h.failed(errProduce)
+	failing := get(h, "/readyz")
+	h.succeeded()
+	recovered := get(h, "/readyz")
+	h.stop()
+	stopping := get(h, "/readyz")
+
+	//Assert
+	assert.Equal(t, starting, http.StatusServiceUnavailable)
+	assert.Equal(t, joined, http.StatusOK)
+	assert.Equal(t, failing, http.StatusServiceUnavailable)
+	assert.Equal(t, recovered, http.StatusOK)
+	assert.Equal(t, stopping, http.StatusServiceUnavailable)
+	assert.Equal(t, get(h, "/healthz"), http.StatusOK)
+}
+
+func TestHealthRebalancing(t *testing.T) {
+	//Arrange
+	h := newHealth()
+	h.rebalanced()
+
+	//Act
+	h.rebalancing()
+
+	//Assert
+	assert.Equal(t, h.ready(), errNotJoined)
+}
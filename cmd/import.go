@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+type importParameters struct {
+	input           string
+	format          string
+	fileCompression string
+}
+
+var (
+	importParams importParameters
+
+	errMissingInput         = errors.New("input file, directory or S3 location must be set")
+	errMissingTargetBrokers = errors.New("target brokers must be set")
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import --to-brokers [url] --to [target] --input [file, directory or s3://bucket/prefix]",
+	Short: "Import the content of files into a topic",
+	Long: `
+	Import reads the events stored in files written by the export command, or in hand-written JSON Lines fixtures, and produces them in the target topic.
+
+	When the input is a directory, every file with a known extension is imported, in name order.
+	The format and compression are detected from the file extensions, unless they are specified. Use "-" as input to read from the standard input.
+	When the input holds the manifest of an export, only the files it lists are imported, partition by partition. The manifest itself can be given as input.
+
+	The input can also be an S3 location (s3://bucket/prefix) written by export, see the export command for the S3 options.
+
+	The events go through the same hashing, filtering and transformation options as when cloning.
+	`,
+	Run: Import,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importParams.input, "input", "i", "", "file, directory or S3 location (s3://bucket/prefix) to import, - for the standard input")
+	importCmd.Flags().StringVar(&importParams.format, "format", "", fmt.Sprintf("file format, detected from the file extensions by default (possible values: %s)", strings.Join(archive.Formats, ", ")))
+	importCmd.Flags().StringVar(&importParams.fileCompression, "file-compression", "", fmt.Sprintf("file compression, detected from the file extensions by default (possible values: %s)", strings.Join(archive.Compressions, ", ")))
+	addS3Flags(importCmd)
+}
+
+//Import handles the reading / producing process
+func Import(cmd *cobra.Command, args []string) {
+
+	if err := importParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	stats := newSummary()
+	pipe, err := params.buildPipeline(stats)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	_, toBrokers := getBrokers()
+
+	fails, err := params.buildFailures(toBrokers)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	sized := newOversized(params.onOversized, func(topic string) (int, error) {
+		return kafka.MaxMessageBytes(toBrokers, topic)
+	})
+	fields, err := params.buildFieldPartitioning(toBrokers)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	send, closeSink := newSink(toBrokers, fails, sized, fields, nil)
+
+	//Try to gracefully shutdown, the produce failures being known once the producer is closed
+	defer func() {
+		closeSink()
+		fails.count(stats)
+		sized.count(stats)
+		fields.count(stats)
+		stats.print()
+		stats.exitOnLoss()
+	}()
+
+	w := startWorkers(params.workers, pipe, nil, send)
+	defer w.stop(stats)
+
+	produce := func(rec archive.Record) error {
+		w.dispatch(rec.ConsumerMessage(params.toTopic))
+		return nil
+	}
+
+	if err := importParams.read(produce); err != nil {
+		log.Print(err)
+	}
+}
+
+func (i importParameters) read(handle func(archive.Record) error) error {
+	if i.input == "-" {
+		format, compression := i.format, i.fileCompression
+		if format == "" {
+			format = archive.FormatJSONL
+		}
+		if compression == "" {
+			compression = archive.CompressionNone
+		}
+		return archive.ReadFile(os.Stdin, format, compression, handle)
+	}
+
+	if archive.IsS3URL(i.input) {
+		s3, err := s3Params.newS3(i.input)
+		if err != nil {
+			return err
+		}
+		return archive.Import(s3, i.format, i.fileCompression, handle)
+	}
+
+	if filepath.Base(i.input) == archive.ManifestName {
+		return archive.ImportManifest(archive.Dir(filepath.Dir(i.input)), handle)
+	}
+
+	info, err := os.Stat(i.input)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return archive.Import(archive.Dir(i.input), i.format, i.fileCompression, handle)
+	}
+	return archive.Import(archive.File(i.input), i.format, i.fileCompression, handle)
+}
+
+func (i importParameters) validate(p parameters) error {
+	switch true {
+
+	case p.toTopic == "":
+		return errMissingTargetTopic
+
+	case p.toBrokers == "":
+		return errMissingTargetBrokers
+
+	case i.input == "":
+		return errMissingInput
+
+	case i.format != "" && !contains(archive.Formats, i.format):
+		return errUnknownFormat
+
+	case i.fileCompression != "" && !contains(archive.Compressions, i.fileCompression):
+		return errUnknownFileCompression
+
+	case !contains(possibleHashers, p.hasher):
+		return errUnknownHasher
+
+	case !contains(possibleCompressionTypes, p.compressionType):
+		return errUnknownCompressionType
+
+	case p.workers < 1 || p.producers < 1:
+		return errInvalidParallelism
+
+	}
+	return p.validateProducing()
+}
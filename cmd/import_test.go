@@ -0,0 +1,119 @@
+//+build unit
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+)
+
+type importParametersTest struct {
+	params   parameters
+	imp      importParameters
+	expected error
+}
+
+var importParametersTestCases = []importParametersTest{
+	{
+		params:   parameters{toBrokers: "foo", toTopic: "bar", hasher: "murmur2", compressionType: "gzip", workers: 1, producers: 1, onProduceError: "skip", onOversized: "skip"},
+		imp:      importParameters{input: "backup"},
+		expected: nil,
+	},
+	{
+		params:   parameters{toBrokers: "foo", hasher: "murmur2", compressionType: "gzip"},
+		imp:      importParameters{input: "backup"},
+		expected: errMissingTargetTopic,
+	},
+	{
+		params:   parameters{toTopic: "bar", hasher: "murmur2", compressionType: "gzip"},
+		imp:      importParameters{input: "backup"},
+		expected: errMissingTargetBrokers,
+	},
+	{
+		params:   parameters{toBrokers: "foo", toTopic: "bar", hasher: "murmur2", compressionType: "gzip"},
+		imp:      importParameters{},
+		expected: errMissingInput,
+	},
+	{
+		params:   parameters{toBrokers: "foo", toTopic: "bar", hasher: "murmur2", compressionType: "gzip"},
+		imp:      importParameters{input: "backup", format: "csv"},
+		expected: errUnknownFormat,
+	},
+	{
+		params:   parameters{toBrokers: "foo", toTopic: "bar", hasher: "murmur2", compressionType: "gzip"},
+		imp:      importParameters{input: "backup", fileCompression: "bzip2"},
+		expected: errUnknownFileCompression,
+	},
+	{
+		params:   parameters{toBrokers: "foo", toTopic: "bar", hasher: "murmur", compressionType: "gzip"},
+		imp:      importParameters{input: "backup"},
+		expected: errUnknownHasher,
+	},
+}
+
+func TestValidateImportParameters(t *testing.T) {
+	for _, v := range importParametersTestCases {
+		//Act
+		actual := v.imp.validate(v.params)
+
+		//Assert
+		assert.Equal(t, actual, v.expected)
+	}
+}
+
+func TestImportRead(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "import")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.jsonl")
+	err = ioutil.WriteFile(fixture, []byte("{\"key\":\"foo\"}\n{\"key\":\"bar\"}\n"), 0644)
+	assert.Equal(t, err, nil)
+
+	for _, input := range []string{dir, fixture} {
+		var keys []string
+
+		//Act
+		err := importParameters{input: input}.read(func(r archive.Record) error {
+			keys = append(keys, string(r.Key))
+			return nil
+		})
+
+		//Assert
+		assert.Equal(t, err, nil)
+		assert.Equal(t, keys, []string{"foo", "bar"})
+	}
+}
+
+func TestImportReadManifest(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "import")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+	exporter, _ := archive.NewExporter(archive.Dir(dir), "foo", archive.FormatJSONL, archive.CompressionGzip, 0)
+	exporter.Write(archive.Record{Partition: 1, Offset: 4, Key: []byte("foo")})
+	exporter.Write(archive.Record{Partition: 0, Offset: 2, Key: []byte("bar")})
+	assert.Equal(t, exporter.Close(), nil)
+	// Files left next to the export are not imported
+	err = ioutil.WriteFile(filepath.Join(dir, "leftover.jsonl"), []byte("{\"key\":\"baz\"}\n"), 0644)
+	assert.Equal(t, err, nil)
+
+	for _, input := range []string{dir, filepath.Join(dir, archive.ManifestName)} {
+		var keys []string
+
+		//Act
+		err := importParameters{input: input}.read(func(r archive.Record) error {
+			keys = append(keys, string(r.Key))
+			return nil
+		})
+
+		//Assert
+		assert.Equal(t, err, nil)
+		assert.Equal(t, keys, []string{"bar", "foo"})
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//boundCheckInterval is the delay between two checks of the partitions which stopped receiving messages before their bound
+const boundCheckInterval = time.Second
+
+//loopBound bounds the loop-cloning to the messages present in the topic when it started: the messages produced by the
+//cloner itself are dropped rather than cloned again, and done is closed once every partition has been cloned up to its bound.
+type loopBound struct {
+	mu       sync.Mutex
+	ends     map[int32]int64
+	finished map[int32]bool
+	//next holds the offset following the last message consumed of each partition
+	next map[int32]int64
+	done chan struct{}
+}
+
+//newLoopBound returns the bound of a topic with the given watermarks, the offsets from the high watermarks on being out of bounds
+func newLoopBound(marks map[int32]kafka.Watermarks) *loopBound {
+	b := &loopBound{
+		ends:     make(map[int32]int64, len(marks)),
+		finished: make(map[int32]bool, len(marks)),
+		next:     make(map[int32]int64, len(marks)),
+		done:     make(chan struct{}),
+	}
+	for p, m := range marks {
+		b.ends[p] = m.High
+		b.finished[p] = m.Empty()
+		b.next[p] = m.Low
+	}
+	b.check()
+	return b
+}
+
+//trim removes the out-of-bounds messages from the batch, in place, and returns the messages to clone
+func (b *loopBound) trim(batch []*sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := batch[:0]
+	for _, msgC := range batch {
+		end, ok := b.ends[msgC.Partition]
+		if !ok {
+			//The partitions created since the cloning started hold no message to clone
+			continue
+		}
+		if msgC.Offset >= end {
+			//The last offsets may hold no message (compacted or transaction markers), reaching the bound is what matters
+			b.finish(msgC.Partition)
+			continue
+		}
+		kept = append(kept, msgC)
+		b.next[msgC.Partition] = msgC.Offset + 1
+		if msgC.Offset == end-1 {
+			b.finish(msgC.Partition)
+		}
+	}
+	for i := len(kept); i < len(batch); i++ {
+		batch[i] = nil
+	}
+	return kept
+}
+
+func (b *loopBound) finish(partition int32) {
+	if b.finished[partition] {
+		return
+	}
+	b.finished[partition] = true
+	b.check()
+}
+
+//check closes done once every partition is finished
+func (b *loopBound) check() {
+	for _, f := range b.finished {
+		if !f {
+			return
+		}
+	}
+	close(b.done)
+}
+
+//watchTopic finishes the partitions of the topic which have no record left before their bound, see watch.
+//stop must be called once the consuming is over.
+func (b *loopBound) watchTopic(brokers []string, topic string) (stop func(), err error) {
+	probe, err := kafka.NewRecordProbe(brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+	stopWatching := b.watch(boundCheckInterval, probe.Remaining)
+	return func() {
+		stopWatching()
+		probe.Close()
+	}, nil
+}
+
+//watch finishes the partitions which have no record left before their bound: the last offsets of a partition may hold no
+//message to consume (compacted, or transaction markers), no message reaching the bound then. Every interval, remaining checks
+//the partitions which received no message since the previous check, from the offset following their last message.
+//The watching ends once done is closed, or once stop is called, stop returning once the last check is over.
+func (b *loopBound) watch(interval time.Duration, remaining func(partition int32, from, to int64) (bool, error)) (stop func()) {
+	stopping := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		checked := make(map[int32]int64)
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-stopping:
+				return
+			case <-ticker.C:
+			}
+
+			for partition, from := range b.stalled(checked) {
+				left, err := remaining(partition, from, b.ends[partition])
+				if err != nil {
+					log.Printf("cannot check the records left in partition %d: %v", partition, err)
+					continue
+				}
+				if !left {
+					b.mu.Lock()
+					b.finish(partition)
+					b.mu.Unlock()
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stopping) })
+		<-stopped
+	}
+}
+
+//stalled returns the offset following the last message of the unfinished partitions which received no message since the
+//previous call, checked holding the offsets of the previous call
+func (b *loopBound) stalled(checked map[int32]int64) map[int32]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stalled := make(map[int32]int64)
+	for partition, next := range b.next {
+		if b.finished[partition] {
+			continue
+		}
+		if previous, ok := checked[partition]; ok && previous == next {
+			stalled[partition] = next
+		}
+		checked[partition] = next
+	}
+	return stalled
+}
+
+//remaining returns the number of messages to clone, assuming the low watermarks are the offsets the consumer starts from
+func remaining(marks map[int32]kafka.Watermarks) int64 {
+	var n int64
+	for _, m := range marks {
+		if !m.Empty() {
+			n += m.High - m.Low
+		}
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+//+build unit
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//isDone returns true if the channel is closed
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestLoopBoundTrim(t *testing.T) {
+	//Arrange
+	bound := newLoopBound(map[int32]kafka.Watermarks{
+		0: {Low: 0, High: 2},
+		1: {Low: 5, High: 7},
+		2: {Low: 3, High: 3},
+	})
+	first := []*sarama.ConsumerMessage{
+		{Partition: 0, Offset: 0},
+		{Partition: 1, Offset: 5},
+		{Partition: 0, Offset: 1},
+		{Partition: 0, Offset: 2},
+		{Partition: 3, Offset: 0},
+	}
+	second := []*sarama.ConsumerMessage{
+		{Partition: 1, Offset: 6},
+		{Partition: 1, Offset: 7},
+	}
+
+	//Act
+	firstKept := bound.trim(first)
+	firstDone := isDone(bound.done)
+	secondKept := bound.trim(second)
+
+	//Assert
+	assert.Equal(t, len(firstKept), 3)
+	assert.Equal(t, firstKept[2].Offset, int64(1))
+	assert.Equal(t, firstDone, false)
+	assert.Equal(t, len(secondKept), 1)
+	assert.Equal(t, isDone(bound.done), true)
+}
+
+func TestLoopBoundSkippedOffsets(t *testing.T) {
+	//Arrange
+	bound := newLoopBound(map[int32]kafka.Watermarks{0: {Low: 0, High: 4}})
+
+	//Act
+	kept := bound.trim([]*sarama.ConsumerMessage{{Partition: 0, Offset: 1}, {Partition: 0, Offset: 5}})
+
+	//Assert
+	assert.Equal(t, len(kept), 1)
+	assert.Equal(t, isDone(bound.done), true)
+}
+
+func TestLoopBoundEmptyTopic(t *testing.T) {
+	//Act
+	bound := newLoopBound(map[int32]kafka.Watermarks{0: {Low: 3, High: 3}})
+
+	//Assert
+	assert.Equal(t, isDone(bound.done), true)
+}
+
+func TestLoopBoundWatch(t *testing.T) {
+	//Arrange
+	//The last offset of partition 0 holds a transaction marker, no message arriving at its bound
+	bound := newLoopBound(map[int32]kafka.Watermarks{0: {Low: 0, High: 3}, 1: {Low: 0, High: 2}})
+	var mu sync.Mutex
+	var checks []string
+	stop := bound.watch(time.Millisecond, func(partition int32, from, to int64) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		checks = append(checks, fmt.Sprintf("%d:%d-%d", partition, from, to))
+		return partition != 0 || from < 2, nil
+	})
+	defer stop()
+
+	//Act
+	bound.trim([]*sarama.ConsumerMessage{{Partition: 0, Offset: 0}, {Partition: 0, Offset: 1}, {Partition: 1, Offset: 0}})
+	partitionDone := waitDone(bound.done)
+	bound.trim([]*sarama.ConsumerMessage{{Partition: 1, Offset: 1}})
+
+	//Assert
+	assert.Equal(t, partitionDone, false)
+	assert.Equal(t, waitDone(bound.done), true)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, contains(checks, "0:2-3"), true)
+}
+
+func TestLoopBoundWatchStopped(t *testing.T) {
+	//Arrange
+	bound := newLoopBound(map[int32]kafka.Watermarks{0: {Low: 0, High: 3}})
+	stop := bound.watch(time.Millisecond, func(int32, int64, int64) (bool, error) {
+		return true, nil
+	})
+
+	//Act
+	stop()
+
+	//Assert
+	assert.Equal(t, isDone(bound.done), false)
+}
+
+//waitDone returns true if the channel is closed within 100ms
+func waitDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	assert.Equal(t, remaining(map[int32]kafka.Watermarks{0: {Low: 0, High: 2}, 1: {Low: 5, High: 7}, 2: {Low: 3, High: 3}}), int64(4))
+}
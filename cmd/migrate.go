@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+type migrateParameters struct {
+	groups []string
+}
+
+var (
+	migrateParams migrateParameters
+
+	errMigrateLoop    = errors.New("cannot migrate consumer groups when loop-cloning, their topic being the target topic")
+	errMissingGroups  = errors.New("at least one consumer group must be set")
+	errDuplicateGroup = errors.New("consumer groups must be set once")
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate --from-brokers [url] --to-brokers [url] --from [source] --to [target] --group [group]...",
+	Short: "Clone a topic along with the offsets of its consumer groups",
+	Long: `
+	Migrate clones the source topic into the target topic, then commits for every consumer group (--group, repeatable) the offsets
+	on the target topic matching its offsets on the source topic, read before cloning.
+
+	A target partition gets the offset of its first event produced from a source event the group has not consumed yet, or the
+	offset following its last event when the group consumed them all: the group may consume some events again, but misses none.
+	The source partitions without committed offset are assumed not consumed at all, and only the target partitions which
+	received events get an offset. Nothing is committed when events were lost.
+
+	The consumers of the groups must be stopped on the target cluster, the coordinator rejecting the commits otherwise.
+	With --dry-run, nothing is produced nor committed: the offsets of every group are printed, predicted from the current
+	end of the target partitions.
+	`,
+	Run: Migrate,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().StringSliceVar(&migrateParams.groups, "group", nil, "consumer group whose offsets are migrated, repeatable or comma-separated")
+}
+
+//Migrate handles the cloning / committing process
+func Migrate(cmd *cobra.Command, args []string) {
+
+	if err := migrateParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	fromBrokers, toBrokers := getBrokers()
+	m, err := migrateParams.start(fromBrokers, toBrokers)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if err := clone(m.observe); err != nil {
+		log.Print(err)
+		return
+	}
+
+	failed := false
+	for _, group := range migrateParams.groups {
+		offsets := m.translations[group].offsets()
+		if len(offsets) == 0 {
+			log.Printf("no event produced in %s, offsets of %s left unchanged", params.toTopic, group)
+			continue
+		}
+		log.Printf("offsets of %s on %s:\n\t%s", group, params.toTopic, strings.Join(offsetLines(offsets), "\n\t"))
+		if params.dryRun {
+			continue
+		}
+		if err := kafka.CommitOffsets(toBrokers, group, params.toTopic, offsets); err != nil {
+			log.Printf("cannot commit the offsets of %s: %v", group, err)
+			failed = true
+		}
+	}
+	if params.dryRun {
+		log.Print("dry run, nothing committed")
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//migration translates the offsets of the consumer groups as the messages are produced. It is safe for concurrent use.
+type migration struct {
+	mu           sync.Mutex
+	translations map[string]*translation
+	//next holds the offset the next message of each target partition would get in dry-run mode, nil otherwise
+	next map[int32]int64
+}
+
+//start reads the offsets of the groups on the source topic, and in dry-run mode the end of the target partitions
+func (m migrateParameters) start(fromBrokers, toBrokers []string) (*migration, error) {
+	mig := &migration{translations: make(map[string]*translation, len(m.groups))}
+	for _, group := range m.groups {
+		committed, err := kafka.CommittedOffsets(fromBrokers, group, params.fromTopic)
+		if err != nil {
+			return nil, err
+		}
+		mig.translations[group] = newTranslation(params.toTopic, committed)
+	}
+
+	if params.dryRun {
+		marks, err := kafka.TopicWatermarks(toBrokers, params.toTopic)
+		if err != nil {
+			return nil, err
+		}
+		mig.next = make(map[int32]int64, len(marks))
+		for partition, mark := range marks {
+			mig.next[partition] = mark.High
+		}
+	}
+	return mig, nil
+}
+
+//observe adds a produced message to the translations, with its predicted offset in dry-run mode
+func (m *migration) observe(msg *sarama.ProducerMessage) {
+	d, ok := msg.Metadata.(delivery)
+	if !ok || !d.sourced {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.next != nil {
+		msg.Offset = m.next[msg.Partition]
+		m.next[msg.Partition]++
+	}
+	mapping := offsetMapping{
+		sourcePartition: d.partition,
+		sourceOffset:    d.offset,
+		targetTopic:     msg.Topic,
+		targetPartition: msg.Partition,
+		targetOffset:    msg.Offset,
+	}
+	for _, t := range m.translations {
+		t.add(mapping)
+	}
+}
+
+func (m migrateParameters) validate(p parameters) error {
+	switch true {
+
+	case p.loop:
+		return errMigrateLoop
+
+	case len(m.groups) == 0:
+		return errMissingGroups
+
+	case contains(m.groups, ""):
+		return errMissingGroup
+
+	case hasDuplicate(m.groups):
+		return errDuplicateGroup
+
+	}
+	return p.validate()
+}
+
+func hasDuplicate(s []string) bool {
+	seen := make(map[string]bool, len(s))
+	for _, e := range s {
+		if seen[e] {
+			return true
+		}
+		seen[e] = true
+	}
+	return false
+}
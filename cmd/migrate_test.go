@@ -0,0 +1,121 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestValidateMigrateParameters(t *testing.T) {
+	valid := parameters{
+		fromBrokers:     "foo",
+		toBrokers:       "bar",
+		fromTopic:       "foo",
+		toTopic:         "foo",
+		hasher:          "murmur2",
+		compressionType: "gzip",
+		workers:         1,
+		producers:       1,
+		batchSize:       1,
+		copies:          1,
+		onProduceError:  "fail",
+		onOversized:     "skip",
+	}
+	loop := valid
+	loop.loop = true
+	loop.toTopic = ""
+	noTarget := valid
+	noTarget.toTopic = ""
+
+	tests := []struct {
+		migrate  migrateParameters
+		params   parameters
+		expected error
+	}{
+		{migrate: migrateParameters{groups: []string{"foo", "bar"}}, params: valid, expected: nil},
+		{migrate: migrateParameters{}, params: valid, expected: errMissingGroups},
+		{migrate: migrateParameters{groups: []string{"foo", ""}}, params: valid, expected: errMissingGroup},
+		{migrate: migrateParameters{groups: []string{"foo", "bar", "foo"}}, params: valid, expected: errDuplicateGroup},
+		{migrate: migrateParameters{groups: []string{"foo"}}, params: loop, expected: errMigrateLoop},
+		{migrate: migrateParameters{groups: []string{"foo"}}, params: noTarget, expected: errMissingTargetTopic},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := tt.migrate.validate(tt.params)
+
+		//Assert
+		assert.Equal(t, actual, tt.expected)
+	}
+}
+
+func TestMigrationObserve(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     map[int32]int64
+		produced []*sarama.ProducerMessage
+		expected map[string]map[int32]int64
+	}{
+		{
+			name: "produced",
+			produced: []*sarama.ProducerMessage{
+				migrated(0, 4, 0, 100),
+				migrated(0, 5, 1, 200),
+				migrated(1, 9, 0, 101),
+				{Topic: "target", Partition: 1, Offset: 300},
+			},
+			expected: map[string]map[int32]int64{
+				"consumed": {0: 102, 1: 201},
+				"behind":   {0: 100, 1: 200},
+			},
+		},
+		{
+			name: "dry run",
+			next: map[int32]int64{0: 10, 1: 0},
+			produced: []*sarama.ProducerMessage{
+				migrated(0, 4, 0, 0),
+				migrated(0, 5, 1, 0),
+				migrated(1, 9, 0, 0),
+			},
+			expected: map[string]map[int32]int64{
+				"consumed": {0: 12, 1: 1},
+				"behind":   {0: 10, 1: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Arrange
+			m := &migration{
+				translations: map[string]*translation{
+					"consumed": newTranslation("target", map[int32]int64{0: 6, 1: 10}),
+					"behind":   newTranslation("target", nil),
+				},
+				next: tt.next,
+			}
+
+			//Act
+			for _, msg := range tt.produced {
+				m.observe(msg)
+			}
+
+			//Assert
+			for group, expected := range tt.expected {
+				assert.Equal(t, m.translations[group].offsets(), expected, group)
+			}
+		})
+	}
+}
+
+func migrated(sourcePartition int32, sourceOffset int64, targetPartition int32, targetOffset int64) *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{
+		Topic:     "target",
+		Partition: targetPartition,
+		Offset:    targetOffset,
+		Metadata:  delivery{sourced: true, partition: sourcePartition, offset: sourceOffset},
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+type mirrorParameters struct {
+	group            string
+	commitInterval   time.Duration
+	reconnectBackoff time.Duration
+	healthAddr       string
+}
+
+var (
+	mirrorParams mirrorParameters
+
+	errMirrorLoop          = errors.New("cannot mirror a topic into itself")
+	errMirrorDryRun        = errors.New("cannot mirror in dry-run mode, the offsets would be committed without producing anything")
+	errMirrorCompact       = errors.New("cannot compact when mirroring, the messages being consumed indefinitely")
+	errMissingGroup        = errors.New("consumer group must be set")
+	errInvalidMirrorDelays = errors.New("commit interval and reconnect backoff must be positive")
+	errRebalanceFailed     = errors.New("the rebalance of the consumer group failed")
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror --from-brokers [url] --from [source] --to-brokers [url] --to [target]",
+	Short: "Continuously mirror a topic into another one",
+	Long: `
+	Mirror consumes the source topic indefinitely and produces its events in the target topic, to be run as a service: it never
+	stops on idle, only when interrupted, and resumes where it stopped when restarted.
+
+	The offsets of the events are committed for the consumer group (--group) once the events produced for them are acknowledged
+	by the target cluster, every --commit-interval and when stopping. Several mirrors of the same topic sharing a group split its partitions.
+	The brokers which become unavailable are reconnected to after --reconnect-backoff, doubled after each failed attempt, when
+	connecting as well as while mirroring.
+
+	With --health-addr, /healthz answers as long as the mirror runs, and /readyz once the consumer owns its partitions, until an error occurs.
+
+	The events go through the same hashing, filtering and transformation options as when cloning.
+	`,
+	Run: Mirror,
+}
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+
+	mirrorCmd.Flags().StringVar(&mirrorParams.group, "group", consumerGroup+"-mirror", "consumer group committing the mirrored offsets")
+	mirrorCmd.Flags().DurationVar(&mirrorParams.commitInterval, "commit-interval", 5*time.Second, "delay between two commits of the acknowledged offsets")
+	mirrorCmd.Flags().DurationVar(&mirrorParams.reconnectBackoff, "reconnect-backoff", 2*time.Second, "delay before reconnecting to an unavailable broker, doubled after each failed attempt up to a minute")
+	mirrorCmd.Flags().StringVar(&mirrorParams.healthAddr, "health-addr", "", "address serving the /healthz and /readyz endpoints (e.g. :8080), none by default")
+}
+
+//Mirror handles the continuous consuming / producing process
+func Mirror(cmd *cobra.Command, args []string) {
+
+	if err := mirrorParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	//The mirror never stops on idle
+	params.timeout = 0
+
+	stats := newSummary()
+	pipe, err := params.buildPipeline(stats)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	fromBrokers, toBrokers := getBrokers()
+
+	h := newHealth()
+	if mirrorParams.healthAddr != "" {
+		h.serve(mirrorParams.healthAddr)
+		log.Printf("health endpoints served on %s", mirrorParams.healthAddr)
+	}
+
+	consumer := mirrorParams.connect(fromBrokers, h)
+	if consumer == nil {
+		return
+	}
+	if params.verbose {
+		log.Printf("consumer (group: %s) initialized on %s/%s", mirrorParams.group, fromBrokers, params.fromTopic)
+	}
+
+	fails, err := params.buildFailures(toBrokers)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	sized := newOversized(params.onOversized, func(topic string) (int, error) {
+		return kafka.MaxMessageBytes(toBrokers, topic)
+	})
+	fields, err := params.buildFieldPartitioning(toBrokers)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	mapping, err := params.openOffsetMap()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	onSuccess := acknowledge
+	if mapping != nil {
+		//The message is recorded before being acknowledged, so that its line is flushed before its offset is committed
+		onSuccess = func(msg *sarama.ProducerMessage) {
+			mapping.record(msg)
+			acknowledge(msg)
+		}
+	}
+	acked := newAcks()
+	send, closeSink := newSink(toBrokers, fails, sized, fields, onSuccess)
+
+	go func() {
+		for err := range consumer.Errors() {
+			log.Printf("Error: %s\n", err.Error())
+			h.failed(err)
+		}
+	}()
+	go func() {
+		for ntf := range consumer.Notifications() {
+			log.Printf("Rebalanced: %+v\n", ntf)
+			switch ntf.Type {
+			case cluster.RebalanceStart:
+				//The acknowledged offsets are committed before the partitions are handed over, to limit the events mirrored twice
+				h.rebalancing()
+				commit(consumer, acked, mapping, h)
+			case cluster.RebalanceOK:
+				h.rebalanced()
+			case cluster.RebalanceError:
+				h.failed(errRebalanceFailed)
+			}
+		}
+	}()
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(mirrorParams.commitInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				commit(consumer, acked, mapping, h)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	//Mirroring loop
+	w := startWorkers(params.workers, pipe, acked, send)
+	consumeBatches(consumer.Messages(), params.batchSize, nil, func(batch []*sarama.ConsumerMessage) {
+		h.succeeded()
+		w.dispatchBatch(batch)
+	})
+
+	//Graceful shutdown: the consumed events are produced, and their offsets committed
+	h.stop()
+	close(stop)
+	w.stop(stats)
+	closeSink()
+	commit(consumer, acked, mapping, h)
+	if mapping != nil {
+		if err := mapping.close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := consumer.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fails.count(stats)
+	sized.count(stats)
+	fields.count(stats)
+	stats.print()
+	stats.exitOnLoss()
+}
+
+//connect returns the consumer of the mirror, retrying with an exponential backoff while the brokers are unavailable.
+//It returns nil if the application is interrupted meanwhile.
+func (m mirrorParameters) connect(brokers []string, h *health) *cluster.Consumer {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, os.Kill)
+	defer signal.Stop(signals)
+
+	for attempt := 1; ; attempt++ {
+		consumer, err := kafka.NewMirrorConsumer(params.fromTopic, brokers, m.group, m.reconnectBackoff, m.commitInterval)
+		if err == nil {
+			return consumer
+		}
+		h.failed(err)
+		backoff := kafka.ExponentialBackoff(m.reconnectBackoff, attempt)
+		log.Printf("cannot connect to %s, retrying in %s: %v", brokers, backoff, err)
+
+		select {
+		case <-signals:
+			log.Print("terminating application")
+			return nil
+		case <-time.After(backoff):
+		}
+	}
+}
+
+//commit marks the acknowledged offsets, and commits them. The offset map is flushed first, so that it covers the committed offsets.
+func commit(consumer *cluster.Consumer, acked *acks, mapping *offsetMap, h *health) {
+	offsets := acked.acknowledged()
+	if mapping != nil {
+		if err := mapping.flush(); err != nil {
+			log.Fatalf("cannot write the offset map: %v", err)
+		}
+	}
+	for partition, offset := range offsets {
+		consumer.MarkPartitionOffset(params.fromTopic, partition, offset, "")
+	}
+	if err := consumer.CommitOffsets(); err != nil {
+		log.Printf("cannot commit the offsets: %v", err)
+		h.failed(err)
+		return
+	}
+	if len(offsets) > 0 {
+		h.succeeded()
+		if params.verbose {
+			log.Printf("offsets committed: %v", offsets)
+		}
+	}
+}
+
+func (m mirrorParameters) validate(p parameters) error {
+	switch true {
+
+	case p.loop:
+		return errMirrorLoop
+
+	case p.dryRun:
+		return errMirrorDryRun
+
+	case p.compact:
+		return errMirrorCompact
+
+	case m.group == "":
+		return errMissingGroup
+
+	case m.commitInterval <= 0 || m.reconnectBackoff <= 0:
+		return errInvalidMirrorDelays
+
+	}
+	return p.validate()
+}
@@ -0,0 +1,58 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestValidateMirrorParameters(t *testing.T) {
+	valid := parameters{
+		fromBrokers:     "foo",
+		toBrokers:       "bar",
+		fromTopic:       "foo",
+		toTopic:         "foo",
+		hasher:          "murmur2",
+		compressionType: "gzip",
+		workers:         1,
+		producers:       1,
+		batchSize:       1,
+		copies:          1,
+		onProduceError:  "fail",
+		onOversized:     "skip",
+	}
+	looping := valid
+	looping.toTopic = ""
+	looping.loop = true
+	dryRun := valid
+	dryRun.dryRun = true
+	compact := valid
+	compact.compact = true
+	invalid := valid
+	invalid.hasher = "foo"
+
+	tests := []struct {
+		mirror   mirrorParameters
+		params   parameters
+		expected error
+	}{
+		{mirror: mirrorParameters{group: "foo", commitInterval: time.Second, reconnectBackoff: time.Second}, params: valid, expected: nil},
+		{mirror: mirrorParameters{group: "foo", commitInterval: time.Second, reconnectBackoff: time.Second}, params: looping, expected: errMirrorLoop},
+		{mirror: mirrorParameters{group: "foo", commitInterval: time.Second, reconnectBackoff: time.Second}, params: dryRun, expected: errMirrorDryRun},
+		{mirror: mirrorParameters{group: "foo", commitInterval: time.Second, reconnectBackoff: time.Second}, params: compact, expected: errMirrorCompact},
+		{mirror: mirrorParameters{commitInterval: time.Second, reconnectBackoff: time.Second}, params: valid, expected: errMissingGroup},
+		{mirror: mirrorParameters{group: "foo", reconnectBackoff: time.Second}, params: valid, expected: errInvalidMirrorDelays},
+		{mirror: mirrorParameters{group: "foo", commitInterval: time.Second, reconnectBackoff: time.Second}, params: invalid, expected: errUnknownHasher},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := tt.mirror.validate(tt.params)
+
+		//Assert
+		assert.Equal(t, actual, tt.expected)
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"path"
+
+	"github.com/ricardo-ch/kafka-topic-cloner/archive"
+	"github.com/spf13/cobra"
+)
+
+type s3Parameters struct {
+	endpoint string
+	region   string
+	partSize int
+}
+
+var s3Params s3Parameters
+
+//addS3Flags adds the flags configuring the access to S3 locations, shared by export and import
+func addS3Flags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&s3Params.endpoint, "s3-endpoint", "", "endpoint of the S3-compatible object storage, e.g. http://localhost:9000 for MinIO (default AWS S3 of the region)")
+	flags.StringVar(&s3Params.region, "s3-region", "us-east-1", "region of the S3 bucket")
+	flags.IntVar(&s3Params.partSize, "s3-part-size", archive.MinPartSize, "size (bytes) of the parts of S3 multipart uploads")
+}
+
+//newS3 returns the storage of an s3://bucket/prefix location, credentials come from the AWS_* environment variables
+func (s s3Parameters) newS3(location string) (*archive.S3, error) {
+	// Pointing at the manifest of an export restores the export
+	if path.Base(location) == archive.ManifestName {
+		location = path.Dir(location)
+	}
+	return archive.NewS3(location, archive.S3Config{
+		Endpoint: s.endpoint,
+		Region:   s.region,
+		PartSize: s.partSize,
+	})
+}
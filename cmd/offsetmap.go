@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+//offsetMapHeader is the first line of an offset map, naming its columns
+var offsetMapHeader = []string{"source_partition", "source_offset", "target_topic", "target_partition", "target_offset"}
+
+var errInvalidOffsetMap = errors.New("invalid offset map line, expecting source_partition,source_offset,target_topic,target_partition,target_offset")
+
+//offsetMap records where every consumed message was produced, as CSV lines appended to a file. It is safe for concurrent use.
+type offsetMap struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	csv  *csv.Writer
+}
+
+//openOffsetMap opens the offset map configured by the parameters, nil if none is configured.
+//The lines are appended to the file, so that a mirror restarted or a clone resumed keeps the lines of its previous runs.
+func (p parameters) openOffsetMap() (*offsetMap, error) {
+	if p.offsetMap == "" || p.dryRun {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(p.offsetMap, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	m := &offsetMap{file: file, buf: bufio.NewWriter(file)}
+	m.csv = csv.NewWriter(m.buf)
+	if info.Size() == 0 {
+		if err := m.csv.Write(offsetMapHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+//record records where a message was produced, if it carries the partition and offset of its consumed message
+func (m *offsetMap) record(msg *sarama.ProducerMessage) {
+	d, ok := msg.Metadata.(delivery)
+	if !ok || !d.sourced {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	//The errors are kept by the CSV writer, and returned when flushing
+	m.csv.Write([]string{
+		strconv.Itoa(int(d.partition)),
+		strconv.FormatInt(d.offset, 10),
+		msg.Topic,
+		strconv.Itoa(int(msg.Partition)),
+		strconv.FormatInt(msg.Offset, 10),
+	})
+}
+
+//flush writes the recorded lines to the file
+func (m *offsetMap) flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.csv.Flush()
+	if err := m.csv.Error(); err != nil {
+		return err
+	}
+	return m.buf.Flush()
+}
+
+//close flushes and closes the file, once the producer is closed
+func (m *offsetMap) close() error {
+	if err := m.flush(); err != nil {
+		return err
+	}
+	return m.file.Close()
+}
+
+//offsetMapping is a line of an offset map
+type offsetMapping struct {
+	sourcePartition int32
+	sourceOffset    int64
+	targetTopic     string
+	targetPartition int32
+	targetOffset    int64
+}
+
+//readOffsetMap calls handle with every line of an offset map
+func readOffsetMap(r io.Reader, handle func(offsetMapping)) error {
+	lines := csv.NewReader(bufio.NewReader(r))
+	lines.FieldsPerRecord = len(offsetMapHeader)
+
+	for {
+		line, err := lines.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		//The header is repeated when several offset maps are concatenated
+		if line[0] == offsetMapHeader[0] {
+			continue
+		}
+
+		mapping, err := parseOffsetMapping(line)
+		if err != nil {
+			return fmt.Errorf("%v: %q", err, line)
+		}
+		handle(mapping)
+	}
+}
+
+func parseOffsetMapping(line []string) (offsetMapping, error) {
+	sourcePartition, err1 := strconv.ParseInt(line[0], 10, 32)
+	sourceOffset, err2 := strconv.ParseInt(line[1], 10, 64)
+	targetPartition, err3 := strconv.ParseInt(line[3], 10, 32)
+	targetOffset, err4 := strconv.ParseInt(line[4], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return offsetMapping{}, errInvalidOffsetMap
+	}
+	return offsetMapping{
+		sourcePartition: int32(sourcePartition),
+		sourceOffset:    sourceOffset,
+		targetTopic:     line[2],
+		targetPartition: int32(targetPartition),
+		targetOffset:    targetOffset,
+	}, nil
+}
+
+//translation computes the offsets of a consumer group on the target topic from its offsets on the source topic. A target
+//partition gets the offset of its first message produced for a source message the group has not consumed yet, or the offset
+//following its last message when the group consumed them all: the group may consume some messages again, but misses none.
+type translation struct {
+	topic     string
+	committed map[int32]int64
+	//first holds the offset of the first message not consumed yet, last the offset of the last message, of each target partition
+	first map[int32]int64
+	last  map[int32]int64
+}
+
+//newTranslation returns the translation to the target topic of the offsets committed on the source topic. The source
+//partitions without committed offset are assumed not consumed at all.
+func newTranslation(topic string, committed map[int32]int64) *translation {
+	return &translation{
+		topic:     topic,
+		committed: committed,
+		first:     make(map[int32]int64),
+		last:      make(map[int32]int64),
+	}
+}
+
+func (t *translation) add(m offsetMapping) {
+	if m.targetTopic != t.topic {
+		return
+	}
+
+	if last, ok := t.last[m.targetPartition]; !ok || m.targetOffset > last {
+		t.last[m.targetPartition] = m.targetOffset
+	}
+
+	committed, ok := t.committed[m.sourcePartition]
+	if ok && m.sourceOffset < committed {
+		return
+	}
+	if first, ok := t.first[m.targetPartition]; !ok || m.targetOffset < first {
+		t.first[m.targetPartition] = m.targetOffset
+	}
+}
+
+//offsets returns the offsets to commit on the target partitions found in the offset map
+func (t *translation) offsets() map[int32]int64 {
+	offsets := make(map[int32]int64, len(t.last))
+	for partition, last := range t.last {
+		if first, ok := t.first[partition]; ok {
+			offsets[partition] = first
+			continue
+		}
+		offsets[partition] = last + 1
+	}
+	return offsets
+}
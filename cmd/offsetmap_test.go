@@ -0,0 +1,108 @@
+//+build unit
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestOffsetMapRecord(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "offsetmap")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+	p := parameters{offsetMap: filepath.Join(dir, "foo.csv")}
+
+	produced := &sarama.ProducerMessage{Topic: "bar", Partition: 2, Offset: 40}
+	attach(produced, &sarama.ConsumerMessage{Partition: 1, Offset: 7}, nil)
+	untracked := &sarama.ProducerMessage{Topic: "bar", Partition: 0, Offset: 3}
+
+	//Act
+	for run := 0; run < 2; run++ {
+		mapping, err := p.openOffsetMap()
+		assert.Equal(t, err, nil)
+		mapping.record(produced)
+		mapping.record(untracked)
+		assert.Equal(t, mapping.close(), nil)
+	}
+	content, err := ioutil.ReadFile(p.offsetMap)
+
+	//Assert
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(content), "source_partition,source_offset,target_topic,target_partition,target_offset\n1,7,bar,2,40\n1,7,bar,2,40\n")
+}
+
+func TestOffsetMapDryRun(t *testing.T) {
+	//Act
+	mapping, err := parameters{offsetMap: "foo.csv", dryRun: true}.openOffsetMap()
+
+	//Assert
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mapping == nil, true)
+}
+
+func TestReadOffsetMap(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []offsetMapping
+		err      bool
+	}{
+		{
+			input: "source_partition,source_offset,target_topic,target_partition,target_offset\n1,7,bar,2,40\n0,3,bar,1,12\n",
+			expected: []offsetMapping{
+				{sourcePartition: 1, sourceOffset: 7, targetTopic: "bar", targetPartition: 2, targetOffset: 40},
+				{sourcePartition: 0, sourceOffset: 3, targetTopic: "bar", targetPartition: 1, targetOffset: 12},
+			},
+		},
+		{input: "1,seven,bar,2,40\n", err: true},
+		{input: "1,7,bar\n", err: true},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		var actual []offsetMapping
+
+		//Act
+		err := readOffsetMap(strings.NewReader(tt.input), func(m offsetMapping) {
+			actual = append(actual, m)
+		})
+
+		//Assert
+		assert.Equal(t, err != nil, tt.err)
+		if !tt.err {
+			assert.Equal(t, actual, tt.expected)
+		}
+	}
+}
+
+func TestTranslation(t *testing.T) {
+	//Arrange
+	//Source partition 0 was consumed up to offset 2 (excluded), source partition 1 entirely, source partition 2 not at all
+	tr := newTranslation("bar", map[int32]int64{0: 2, 1: 10})
+	mappings := []offsetMapping{
+		{sourcePartition: 0, sourceOffset: 0, targetTopic: "bar", targetPartition: 0, targetOffset: 0},
+		{sourcePartition: 1, sourceOffset: 4, targetTopic: "bar", targetPartition: 0, targetOffset: 1},
+		{sourcePartition: 0, sourceOffset: 1, targetTopic: "bar", targetPartition: 1, targetOffset: 0},
+		{sourcePartition: 0, sourceOffset: 2, targetTopic: "bar", targetPartition: 0, targetOffset: 2},
+		{sourcePartition: 0, sourceOffset: 3, targetTopic: "bar", targetPartition: 1, targetOffset: 1},
+		{sourcePartition: 1, sourceOffset: 5, targetTopic: "bar", targetPartition: 0, targetOffset: 3},
+		{sourcePartition: 1, sourceOffset: 6, targetTopic: "bar", targetPartition: 2, targetOffset: 8},
+		{sourcePartition: 2, sourceOffset: 0, targetTopic: "bar", targetPartition: 3, targetOffset: 5},
+		{sourcePartition: 0, sourceOffset: 0, targetTopic: "other", targetPartition: 4, targetOffset: 0},
+	}
+
+	//Act
+	for _, m := range mappings {
+		tr.add(m)
+	}
+
+	//Assert
+	assert.Equal(t, tr.offsets(), map[int32]int64{0: 2, 1: 1, 2: 9, 3: 5})
+}
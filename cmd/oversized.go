@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//Possible policies applied to the messages larger than the target topic accepts
+const (
+	oversizedFail  = "fail"
+	oversizedSkip  = "skip"
+	oversizedSplit = "split"
+)
+
+var (
+	possibleOversizedPolicies = []string{oversizedFail, oversizedSkip, oversizedSplit}
+
+	errUnknownOversizedPolicy = errors.New("unknown oversized record policy, see help for possible value")
+)
+
+//oversized applies the policy to the messages larger than the max.message.bytes of their topic, before they are sent.
+//It is safe for concurrent use.
+type oversized struct {
+	policy string
+	lookup func(topic string) (int, error)
+	exit   func(error)
+
+	mu     sync.Mutex
+	limits map[string]int
+
+	skipped int64
+	split   int64
+}
+
+//newOversized returns the oversized record handling, lookup returning the max.message.bytes of a topic
+func newOversized(policy string, lookup func(topic string) (int, error)) *oversized {
+	return &oversized{
+		policy: policy,
+		lookup: lookup,
+		exit: func(err error) {
+			log.Fatalf("Oversized record, stopping: %v", err)
+		},
+		limits: make(map[string]int),
+	}
+}
+
+//maxMessageBytes returns the max.message.bytes of a topic, looked up once. Kafka's default is assumed if it cannot be described.
+func (o *oversized) maxMessageBytes(topic string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	limit, ok := o.limits[topic]
+	if !ok {
+		var err error
+		if limit, err = o.lookup(topic); err != nil {
+			log.Printf("cannot get the max.message.bytes of %s, assuming %d: %v", topic, kafka.DefaultMaxMessageBytes, err)
+			limit = kafka.DefaultMaxMessageBytes
+		}
+		o.limits[topic] = limit
+	}
+	return limit
+}
+
+//wrap returns a send applying the policy to the oversized messages, and sending the other ones as is
+func (o *oversized) wrap(send func(*sarama.ProducerMessage)) func(*sarama.ProducerMessage) {
+	return func(msg *sarama.ProducerMessage) {
+		maxRecordSize := kafka.MaxRecordSize(o.maxMessageBytes(msg.Topic))
+		size := kafka.RecordSize(msg)
+		if size <= maxRecordSize {
+			send(msg)
+			return
+		}
+
+		err := fmt.Errorf("record of %d bytes, %s accepts up to %d bytes", size, msg.Topic, maxRecordSize)
+		switch o.policy {
+		case oversizedFail:
+			o.exit(err)
+			return
+
+		case oversizedSplit:
+			chunks, splitErr := kafka.Split(msg, maxRecordSize)
+			if splitErr == nil {
+				atomic.AddInt64(&o.split, 1)
+				for _, chunk := range chunks {
+					send(chunk)
+				}
+				return
+			}
+			err = fmt.Errorf("%v: %v", err, splitErr)
+		}
+
+		atomic.AddInt64(&o.skipped, 1)
+		log.Printf("Oversized record skipped: %v", err)
+	}
+}
+
+//count adds the oversized messages to the summary
+func (o *oversized) count(s *summary) {
+	s.oversizedSkipped += int(atomic.LoadInt64(&o.skipped))
+	s.oversizedSplit += int(atomic.LoadInt64(&o.split))
+}
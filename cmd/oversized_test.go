@@ -0,0 +1,85 @@
+//+build unit
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//newTestOversized returns the oversized handling of topics accepting maxMessageBytes, recording the sent messages and exits
+func newTestOversized(policy string, maxMessageBytes int) (o *oversized, send func(*sarama.ProducerMessage), sent *[]*sarama.ProducerMessage, exits *[]error) {
+	sent, exits = &[]*sarama.ProducerMessage{}, &[]error{}
+	o = newOversized(policy, func(topic string) (int, error) {
+		if topic == "unknown" {
+			return 0, errors.New("unknown topic")
+		}
+		return maxMessageBytes, nil
+	})
+	o.exit = func(err error) { *exits = append(*exits, err) }
+	send = o.wrap(func(msg *sarama.ProducerMessage) { *sent = append(*sent, msg) })
+	return
+}
+
+func TestOversizedPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		sent    int
+		exits   int
+		skipped int
+		split   int
+	}{
+		{policy: oversizedSkip, sent: 1, skipped: 1},
+		{policy: oversizedFail, sent: 1, exits: 1},
+		{policy: oversizedSplit, sent: 5, split: 1},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		o, send, sent, exits := newTestOversized(tt.policy, 1000)
+		small := &sarama.ProducerMessage{Topic: "foo", Value: sarama.StringEncoder("bar")}
+		large := &sarama.ProducerMessage{Topic: "foo", Value: sarama.StringEncoder(strings.Repeat("x", 2500))}
+
+		//Act
+		send(small)
+		send(large)
+		stats := newSummary()
+		o.count(stats)
+
+		//Assert
+		assert.Equal(t, len(*sent), tt.sent, tt.policy)
+		assert.Equal(t, (*sent)[0], small, tt.policy)
+		assert.Equal(t, len(*exits), tt.exits, tt.policy)
+		assert.Equal(t, stats.oversizedSkipped, tt.skipped, tt.policy)
+		assert.Equal(t, stats.oversizedSplit, tt.split, tt.policy)
+	}
+}
+
+func TestOversizedCannotSplit(t *testing.T) {
+	//Arrange
+	o, send, sent, _ := newTestOversized(oversizedSplit, 1000)
+
+	//Act
+	send(&sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder(strings.Repeat("k", 2000))})
+
+	//Assert
+	assert.Equal(t, len(*sent), 0)
+	assert.Equal(t, o.skipped, int64(1))
+}
+
+func TestOversizedUnknownLimit(t *testing.T) {
+	//Arrange
+	o, _, _, _ := newTestOversized(oversizedSkip, 1000)
+
+	//Act
+	limit := o.maxMessageBytes("unknown")
+
+	//Assert
+	assert.Equal(t, limit, kafka.DefaultMaxMessageBytes)
+	assert.Equal(t, o.maxMessageBytes("foo"), 1000)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+//Possible encodings of the keys given to partition-for
+const (
+	keyEncodingString = "string"
+	keyEncodingHex    = "hex"
+	keyEncodingBase64 = "base64"
+)
+
+type partitionForParameters struct {
+	partitions int32
+	encoding   string
+}
+
+var (
+	partitionForParams   partitionForParameters
+	possibleKeyEncodings = []string{keyEncodingString, keyEncodingHex, keyEncodingBase64}
+
+	errMissingPartitions  = errors.New("number of partitions must be set, or the source brokers and topic to read it from")
+	errUnknownKeyEncoding = errors.New("unknown key encoding, see help for possible value")
+)
+
+var partitionForCmd = &cobra.Command{
+	Use:   "partition-for --partitions [count] [key...]",
+	Short: "Compute the partition of keys",
+	Long: `
+	Partition-for prints the partition the hasher places each key on, one key per line followed by a tab and its partition.
+
+	The keys are read from the arguments, or from the standard input (one per line) when there is none. They are strings by
+	default, or hex or base64 encoded bytes with --encoding.
+
+	The number of partitions is given with --partitions, or read from the topic given with --from-brokers and --from.
+	Only the hashers placing the events by key can be used: murmur2, FNV-1a, crc32, consistent_random and sticky.
+	`,
+	Run: PartitionFor,
+}
+
+func init() {
+	rootCmd.AddCommand(partitionForCmd)
+
+	partitionForCmd.Flags().Int32Var(&partitionForParams.partitions, "partitions", 0, "number of partitions, read from the source topic by default")
+	partitionForCmd.Flags().StringVar(&partitionForParams.encoding, "encoding", keyEncodingString, fmt.Sprintf("encoding of the keys (possible values: %s)", strings.Join(possibleKeyEncodings, ", ")))
+}
+
+//PartitionFor handles the partition computing process
+func PartitionFor(cmd *cobra.Command, args []string) {
+
+	if err := partitionForParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	partitions := partitionForParams.partitions
+	if partitions == 0 {
+		fromBrokers, _ := getBrokers()
+		count, err := kafka.PartitionCount(fromBrokers, params.fromTopic)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		partitions = count
+	}
+	if params.verbose {
+		log.Printf("partitioning with %s over %d partitions", params.hasher, partitions)
+	}
+
+	var failed bool
+	handle := func(key string) {
+		line, err := partitionForParams.line(key, params.hasher, partitions)
+		if err != nil {
+			log.Printf("%s: %v", key, err)
+			failed = true
+			return
+		}
+		fmt.Println(line)
+	}
+
+	if len(args) > 0 {
+		for _, key := range args {
+			handle(key)
+		}
+	} else if err := readLines(os.Stdin, handle); err != nil {
+		log.Print(err)
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//line returns the key followed by its partition
+func (p partitionForParameters) line(key, hasher string, partitions int32) (string, error) {
+	decoded, err := p.decode(key)
+	if err != nil {
+		return "", err
+	}
+	partition, err := kafka.PartitionFor(decoded, hasher, partitions)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\t%d", key, partition), nil
+}
+
+func (p partitionForParameters) decode(key string) ([]byte, error) {
+	switch p.encoding {
+	case keyEncodingHex:
+		return hex.DecodeString(key)
+	case keyEncodingBase64:
+		return base64.StdEncoding.DecodeString(key)
+	}
+	return []byte(key), nil
+}
+
+//readLines calls handle with every line of r, without its line ending
+func readLines(r io.Reader, handle func(string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		handle(strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	return scanner.Err()
+}
+
+func (p partitionForParameters) validate(params parameters) error {
+	switch true {
+
+	case p.partitions < 0:
+		return errMissingPartitions
+
+	case p.partitions == 0 && (params.fromBrokers == "" || params.fromTopic == ""):
+		return errMissingPartitions
+
+	case !contains(possibleKeyEncodings, p.encoding):
+		return errUnknownKeyEncoding
+
+	case !contains(possibleHashers, params.hasher):
+		return errUnknownHasher
+
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+//+build unit
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+func TestPartitionForLine(t *testing.T) {
+	//With 3 partitions, murmur2 places foo on 2, foobar on 0 and 42 on 1
+	tests := []struct {
+		key      string
+		encoding string
+		hasher   string
+		expected string
+		err      bool
+	}{
+		{key: "foo", encoding: keyEncodingString, hasher: "murmur2", expected: "foo\t2"},
+		{key: "666f6f626172", encoding: keyEncodingHex, hasher: "murmur2", expected: "666f6f626172\t0"},
+		{key: "NDI=", encoding: keyEncodingBase64, hasher: "murmur2", expected: "NDI=\t1"},
+		{key: "NDI=", encoding: keyEncodingBase64, hasher: "FNV-1a", expected: "NDI=\t2"},
+		{key: "zz", encoding: keyEncodingHex, hasher: "murmur2", err: true},
+		{key: "foo", encoding: keyEncodingString, hasher: "random", err: true},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		p := partitionForParameters{encoding: tt.encoding}
+
+		//Act
+		actual, err := p.line(tt.key, tt.hasher, 3)
+
+		//Assert
+		assert.Equal(t, err != nil, tt.err, tt.key)
+		assert.Equal(t, actual, tt.expected, tt.key)
+	}
+}
+
+func TestPartitionForEmptyKey(t *testing.T) {
+	//Arrange
+	p := partitionForParameters{encoding: keyEncodingString}
+
+	//Act
+	_, err := p.line("", "consistent_random", 3)
+
+	//Assert
+	assert.Equal(t, err, kafka.ErrNullKey)
+}
+
+func TestReadLines(t *testing.T) {
+	//Arrange
+	var lines []string
+
+	//Act
+	err := readLines(strings.NewReader("foo\r\n\nbar"), func(line string) { lines = append(lines, line) })
+
+	//Assert
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lines, []string{"foo", "", "bar"})
+}
+
+func TestValidatePartitionForParameters(t *testing.T) {
+	tests := []struct {
+		partitionFor partitionForParameters
+		params       parameters
+		expected     error
+	}{
+		{partitionFor: partitionForParameters{partitions: 3, encoding: "hex"}, params: parameters{hasher: "murmur2"}, expected: nil},
+		{partitionFor: partitionForParameters{encoding: "hex"}, params: parameters{hasher: "murmur2", fromBrokers: "foo", fromTopic: "bar"}, expected: nil},
+		{partitionFor: partitionForParameters{encoding: "hex"}, params: parameters{hasher: "murmur2"}, expected: errMissingPartitions},
+		{partitionFor: partitionForParameters{partitions: 3, encoding: "utf-16"}, params: parameters{hasher: "murmur2"}, expected: errUnknownKeyEncoding},
+		{partitionFor: partitionForParameters{partitions: 3, encoding: "string"}, params: parameters{hasher: "md5"}, expected: errUnknownHasher},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.partitionFor.validate(tt.params), tt.expected)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+var (
+	errInvalidPartitionField  = errors.New("partition by either a JSON field or a header, with the murmur2, FNV-1a or crc32 hasher, and without preserving the partitions")
+	errRewriteKeyWithoutField = errors.New("the key can only be rewritten when partitioning by a JSON field or a header")
+)
+
+//fieldPartitioning partitions the messages on a field of their value or a header before they are sent, the producer
+//producing them on the partition it was given. Without field, the messages are partitioned with the hasher before being
+//split, so that the chunks of a message share its partition. It is safe for concurrent use.
+type fieldPartitioning struct {
+	field          kafka.Field
+	constructor    sarama.PartitionerConstructor
+	rewriteKey     bool
+	partitionCount func(topic string) (int32, error)
+
+	mu           sync.Mutex
+	partitioners map[string]sarama.Partitioner
+	counts       map[string]int32
+
+	errors int64
+}
+
+//buildFieldPartitioning returns the partitioning on the field set by the parameters. Without field, the messages are partitioned
+//with the hasher when they may be split, and sent as is otherwise.
+func (p parameters) buildFieldPartitioning(toBrokers []string) (*fieldPartitioning, error) {
+	f := &fieldPartitioning{
+		rewriteKey: p.rewriteKey,
+		partitionCount: func(topic string) (int32, error) {
+			return kafka.PartitionCount(toBrokers, topic)
+		},
+		partitioners: make(map[string]sarama.Partitioner),
+		counts:       make(map[string]int32),
+	}
+
+	switch {
+	case p.partitionByJSON != "":
+		field, err := kafka.NewJSONField(p.partitionByJSON)
+		if err != nil {
+			return nil, err
+		}
+		f.field = field
+	case p.partitionByHeader != "":
+		f.field = kafka.NewHeaderField(p.partitionByHeader)
+	case p.onOversized == oversizedSplit && !p.preservePartitions:
+		//The hasher could spread the chunks of a message over several partitions: keyless messages, or non-key hashers
+		f.constructor = func(topic string) sarama.Partitioner {
+			return kafka.NewPartitioner(p.hasher, topic)
+		}
+		return f, nil
+	default:
+		return f, nil
+	}
+	f.constructor = kafka.NewFieldPartitioner(p.hasher, f.field)
+	return f, nil
+}
+
+//wrap returns a send setting the partition of the messages, and their key if it must be rewritten. The messages lacking
+//the field are not sent.
+func (f *fieldPartitioning) wrap(send func(*sarama.ProducerMessage)) func(*sarama.ProducerMessage) {
+	if f.constructor == nil {
+		return send
+	}
+	return func(msg *sarama.ProducerMessage) {
+		partition, err := f.partition(msg)
+		if err == nil && f.field != nil && f.rewriteKey {
+			var value []byte
+			value, err = f.field(msg)
+			msg.Key = sarama.ByteEncoder(value)
+		}
+		if err != nil {
+			atomic.AddInt64(&f.errors, 1)
+			log.Printf("Cannot partition message, skipping it: %v", err)
+			return
+		}
+		msg.Partition = partition
+		send(msg)
+	}
+}
+
+func (f *fieldPartitioning) partition(msg *sarama.ProducerMessage) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	partitioner, ok := f.partitioners[msg.Topic]
+	if !ok {
+		count, err := f.partitionCount(msg.Topic)
+		if err != nil {
+			return -1, err
+		}
+		partitioner = f.constructor(msg.Topic)
+		f.partitioners[msg.Topic] = partitioner
+		f.counts[msg.Topic] = count
+	}
+	return partitioner.Partition(msg, f.counts[msg.Topic])
+}
+
+//count adds the messages that could not be partitioned to the summary
+func (f *fieldPartitioning) count(s *summary) {
+	s.partitioningErrors += int(atomic.LoadInt64(&f.errors))
+}
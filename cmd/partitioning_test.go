@@ -0,0 +1,110 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestFieldPartitioning(t *testing.T) {
+	tests := []struct {
+		params       parameters
+		expectedKeys []string
+	}{
+		{
+			params:       parameters{hasher: "murmur2", partitionByJSON: "$.customerId"},
+			expectedKeys: []string{"a", "b", "c"},
+		},
+		{
+			params:       parameters{hasher: "murmur2", partitionByJSON: "$.customerId", rewriteKey: true},
+			expectedKeys: []string{"foo", "foobar", "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		f, err := tt.params.buildFieldPartitioning(nil)
+		assert.Equal(t, err, nil)
+		f.partitionCount = func(topic string) (int32, error) { return 3, nil }
+		var sent []*sarama.ProducerMessage
+		send := f.wrap(func(msg *sarama.ProducerMessage) { sent = append(sent, msg) })
+
+		//Act
+		send(&sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder("a"), Value: sarama.StringEncoder(`{"customerId":"foo"}`)})
+		send(&sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder("b"), Value: sarama.StringEncoder(`{"customerId":"foobar"}`)})
+		send(&sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder("c"), Value: sarama.StringEncoder(`{"customerId":42}`)})
+		send(&sarama.ProducerMessage{Topic: "foo", Key: sarama.StringEncoder("d"), Value: sarama.StringEncoder(`{}`)})
+		stats := newSummary()
+		f.count(stats)
+
+		//Assert
+		//With 3 partitions, murmur2 places foo on 2, foobar on 0 and 42 on 1
+		var partitions []int32
+		var keys []string
+		for _, msg := range sent {
+			partitions = append(partitions, msg.Partition)
+			key, _ := msg.Key.Encode()
+			keys = append(keys, string(key))
+		}
+		assert.Equal(t, partitions, []int32{2, 0, 1})
+		assert.Equal(t, keys, tt.expectedKeys)
+		assert.Equal(t, stats.partitioningErrors, 1)
+	}
+}
+
+func TestFieldPartitioningDisabled(t *testing.T) {
+	//Arrange
+	f, _ := parameters{hasher: "murmur2"}.buildFieldPartitioning(nil)
+	var sent []*sarama.ProducerMessage
+	send := f.wrap(func(msg *sarama.ProducerMessage) { sent = append(sent, msg) })
+
+	//Act
+	send(&sarama.ProducerMessage{Topic: "foo", Partition: 5})
+
+	//Assert
+	assert.Equal(t, len(sent), 1)
+	assert.Equal(t, sent[0].Partition, int32(5))
+	assert.Equal(t, parameters{hasher: "murmur2", partitionByHeader: "customer"}.producerHasher(), "manual")
+}
+
+func TestSplitPartitioning(t *testing.T) {
+	tests := []struct {
+		params      parameters
+		partitioned bool
+	}{
+		{params: parameters{hasher: "random", onOversized: oversizedSplit}, partitioned: true},
+		{params: parameters{hasher: "murmur2", onOversized: oversizedSplit}, partitioned: true},
+		{params: parameters{hasher: "random", onOversized: oversizedSplit, preservePartitions: true}, partitioned: false},
+		{params: parameters{hasher: "random", onOversized: oversizedSkip}, partitioned: false},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		f, err := tt.params.buildFieldPartitioning(nil)
+		assert.Equal(t, err, nil)
+		f.partitionCount = func(topic string) (int32, error) { return 3, nil }
+		sized := newOversized(tt.params.onOversized, func(string) (int, error) { return 1000, nil })
+		var sent []*sarama.ProducerMessage
+		send := f.wrap(sized.wrap(func(msg *sarama.ProducerMessage) { sent = append(sent, msg) }))
+
+		//Act
+		send(&sarama.ProducerMessage{Topic: "foo", Partition: 7, Value: sarama.ByteEncoder(make([]byte, 5000))})
+
+		//Assert
+		if !tt.partitioned {
+			for _, msg := range sent {
+				assert.Equal(t, msg.Partition, int32(7))
+			}
+			continue
+		}
+		assert.Equal(t, len(sent) > 1, true)
+		for _, msg := range sent {
+			assert.Equal(t, msg.Partition, sent[0].Partition)
+			assert.Equal(t, msg.Partition < 3, true)
+		}
+		assert.Equal(t, tt.params.producerHasher(), "manual")
+	}
+}
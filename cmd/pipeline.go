@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//pipeline turns a consumed message into the messages to produce, by applying the filters, transformers and scripts
+type pipeline struct {
+	topic       string
+	filters     kafka.Filters
+	transformer kafka.Transformer
+	scripts     []*kafka.Script
+	redactor    kafka.Transformer
+	remapper    kafka.Transformer
+	stats       *summary
+	verbose     bool
+	copies      int
+
+	preservePartitions bool
+	preserveTimestamps bool
+
+	//sources makes the produced messages carry the partition and offset of their consumed message, see attach
+	sources bool
+}
+
+func (p parameters) buildPipeline(stats *summary) (*pipeline, error) {
+	filters, err := p.buildFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	transformer, err := kafka.NewChain(p.transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []*kafka.Script
+	for _, path := range p.scripts {
+		script, err := kafka.NewScript(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, script)
+	}
+
+	pipe := &pipeline{
+		topic:       p.targetTopic(),
+		filters:     filters,
+		transformer: transformer,
+		scripts:     scripts,
+		stats:       stats,
+		verbose:     p.verbose,
+		copies:      p.copies,
+
+		preservePartitions: p.preservePartitions,
+		preserveTimestamps: p.preserveTimestamps,
+	}
+
+	if len(p.redactRules) > 0 || p.redactKey != "" {
+		redactor, err := kafka.NewRedactor(p.redactSecret, p.redactRules, p.redactKey)
+		if err != nil {
+			return nil, err
+		}
+		pipe.redactor = redactor
+	}
+
+	if p.sourceRegistry != "" {
+		source, err := kafka.NewSchemaRegistry(p.sourceRegistry)
+		if err != nil {
+			return nil, err
+		}
+		target, err := kafka.NewSchemaRegistry(p.targetRegistry)
+		if err != nil {
+			return nil, err
+		}
+		pipe.remapper = kafka.NewSchemaRemapper(source, target, p.registryKeys)
+	}
+	return pipe, nil
+}
+
+//process returns the messages to produce for a consumed message
+func (p *pipeline) process(msgC *sarama.ConsumerMessage) []*sarama.ProducerMessage {
+	var msgs []*sarama.ProducerMessage
+	p.processTo(msgC, func(msgP *sarama.ProducerMessage) {
+		msgs = append(msgs, msgP)
+	})
+	return msgs
+}
+
+//processTo calls emit with the messages to produce for a consumed message, once every step succeeded for all of them.
+//The key, value and header slices of the consumed message are shared with the produced ones, not copied.
+func (p *pipeline) processTo(msgC *sarama.ConsumerMessage, emit func(*sarama.ProducerMessage)) {
+	p.stats.consumed++
+	if p.copies > 1 {
+		emit = p.repeat(emit)
+	}
+	if filter := p.filters.Reject(msgC); filter != nil {
+		p.stats.filter(filter)
+		return
+	}
+
+	msgP := &sarama.ProducerMessage{
+		Topic: p.topic,
+	}
+	if msgC.Value != nil {
+		msgP.Value = sarama.ByteEncoder(msgC.Value)
+	}
+	if msgC.Key != nil {
+		msgP.Key = sarama.ByteEncoder(msgC.Key)
+	}
+	if len(msgC.Headers) > 0 {
+		msgP.Headers = make([]sarama.RecordHeader, 0, len(msgC.Headers))
+		for _, h := range msgC.Headers {
+			msgP.Headers = append(msgP.Headers, *h)
+		}
+	}
+
+	if err := p.transformer.Transform(msgP); err != nil {
+		p.stats.transformErrors++
+		p.skipped(msgC, err)
+		return
+	}
+
+	//Without scripts, which may produce several messages, the message is processed alone
+	if len(p.scripts) == 0 {
+		if p.finish(msgC, msgP) {
+			emit(msgP)
+		}
+		return
+	}
+
+	msgs := []*sarama.ProducerMessage{msgP}
+	for _, script := range p.scripts {
+		var out []*sarama.ProducerMessage
+		for _, msg := range msgs {
+			res, err := script.Run(msg, msgC)
+			if err != nil {
+				p.stats.scriptError(script)
+				p.skipped(msgC, err)
+				return
+			}
+			out = append(out, res...)
+		}
+		msgs = out
+	}
+
+	for _, msg := range msgs {
+		if !p.finish(msgC, msg) {
+			return
+		}
+	}
+	for _, msg := range msgs {
+		emit(msg)
+	}
+}
+
+//finish applies the steps following the scripts to a message, it returns false if the message must not be produced
+func (p *pipeline) finish(msgC *sarama.ConsumerMessage, msg *sarama.ProducerMessage) bool {
+	if p.preservePartitions {
+		msg.Partition = msgC.Partition
+	}
+	if p.preserveTimestamps {
+		msg.Timestamp = msgC.Timestamp
+	}
+
+	if p.redactor != nil {
+		if err := p.redactor.Transform(msg); err != nil {
+			p.stats.redactionErrors++
+			p.skipped(msgC, err)
+			return false
+		}
+	}
+
+	if p.remapper != nil {
+		if err := p.remapper.Transform(msg); err != nil {
+			p.stats.schemaErrors++
+			p.skipped(msgC, err)
+			return false
+		}
+	}
+	return true
+}
+
+//repeat wraps emit to emit every message as many times as there are copies. The copies are made before emitting the
+//message, the producer keeping its own state in the messages it handles.
+func (p *pipeline) repeat(emit func(*sarama.ProducerMessage)) func(*sarama.ProducerMessage) {
+	return func(msg *sarama.ProducerMessage) {
+		copies := make([]*sarama.ProducerMessage, 0, p.copies-1)
+		for i := 1; i < p.copies; i++ {
+			copies = append(copies, copyMessage(msg))
+		}
+		emit(msg)
+		for _, c := range copies {
+			emit(c)
+		}
+	}
+}
+
+func (p *pipeline) skipped(msgC *sarama.ConsumerMessage, err error) {
+	if p.verbose {
+		log.Printf("message at partition %v, offset %v not cloned: %v", msgC.Partition, msgC.Offset, err)
+	}
+}
@@ -0,0 +1,135 @@
+//+build unit
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestPipelineProcess(t *testing.T) {
+	//Arrange
+	dir, err := ioutil.TempDir("", "pipeline")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "split.star")
+	err = ioutil.WriteFile(script, []byte(`
+def transform(record):
+    if record["value"] == "fail":
+        fail("boom")
+    return [record, record]
+`), 0644)
+	assert.Equal(t, err, nil)
+
+	p := parameters{
+		toTopic:    "bar",
+		keyPrefix:  "foo",
+		transforms: []string{"set-header:source=cloner"},
+		scripts:    []string{script},
+	}
+	stats := newSummary()
+	pipe, err := p.buildPipeline(stats)
+	assert.Equal(t, err, nil)
+
+	//Act
+	cloned := pipe.process(&sarama.ConsumerMessage{Key: []byte("foo-1"), Value: []byte("value")})
+	filtered := pipe.process(&sarama.ConsumerMessage{Key: []byte("bar-1"), Value: []byte("value")})
+	failed := pipe.process(&sarama.ConsumerMessage{Key: []byte("foo-2"), Value: []byte("fail")})
+
+	//Assert
+	assert.Equal(t, len(cloned), 2)
+	assert.Equal(t, cloned[0].Topic, "bar")
+	assert.Equal(t, cloned[1].Headers, []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("cloner")}})
+	assert.Equal(t, len(filtered), 0)
+	assert.Equal(t, len(failed), 0)
+	assert.Equal(t, stats.consumed, 3)
+	assert.Equal(t, stats.filtered["key-prefix(foo)"], 1)
+	assert.Equal(t, stats.scriptErrors["split.star"], 1)
+}
+
+func TestPipelineRedaction(t *testing.T) {
+	//Arrange
+	p := parameters{
+		toTopic:      "bar",
+		redactRules:  []string{"email:null"},
+		redactSecret: "secret",
+	}
+	stats := newSummary()
+	pipe, err := p.buildPipeline(stats)
+	assert.Equal(t, err, nil)
+
+	//Act
+	redacted := pipe.process(&sarama.ConsumerMessage{Value: []byte(`{"email":"foo@bar.com"}`)})
+	rejected := pipe.process(&sarama.ConsumerMessage{Value: []byte(`foo@bar.com`)})
+
+	//Assert
+	assert.Equal(t, len(redacted), 1)
+	assert.Equal(t, redacted[0].Value, sarama.ByteEncoder(`{"email":null}`))
+	assert.Equal(t, len(rejected), 0)
+	assert.Equal(t, stats.redactionErrors, 1)
+}
+
+func TestPipelinePreserve(t *testing.T) {
+	//Arrange
+	p := parameters{
+		toTopic:            "bar",
+		preservePartitions: true,
+		preserveTimestamps: true,
+	}
+	pipe, err := p.buildPipeline(newSummary())
+	assert.Equal(t, err, nil)
+	timestamp := time.Unix(1500000000, 0)
+
+	//Act
+	msgs := pipe.process(&sarama.ConsumerMessage{Partition: 3, Timestamp: timestamp})
+
+	//Assert
+	assert.Equal(t, len(msgs), 1)
+	assert.Equal(t, msgs[0].Partition, int32(3))
+	assert.Equal(t, msgs[0].Timestamp, timestamp)
+}
+
+func BenchmarkPipelineProcess(b *testing.B) {
+	pipe, _ := parameters{toTopic: "bar"}.buildPipeline(newSummary())
+	msg := &sarama.ConsumerMessage{
+		Key:     []byte("foo"),
+		Value:   []byte(`{"id":42}`),
+		Headers: []*sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		pipe.processTo(msg, func(*sarama.ProducerMessage) {})
+	}
+}
+
+func TestPipelineCopies(t *testing.T) {
+	//Arrange
+	p := parameters{
+		fromTopic: "foo",
+		loop:      true,
+		copies:    3,
+	}
+	stats := newSummary()
+	pipe, err := p.buildPipeline(stats)
+	assert.Equal(t, err, nil)
+
+	//Act
+	cloned := pipe.process(&sarama.ConsumerMessage{Key: []byte("foo-1"), Value: []byte("value")})
+
+	//Assert
+	assert.Equal(t, len(cloned), 3)
+	for _, msg := range cloned {
+		assert.Equal(t, msg.Topic, "foo")
+		assert.Equal(t, msg.Key, sarama.Encoder(sarama.ByteEncoder("foo-1")))
+	}
+	assert.Equal(t, cloned[0] != cloned[1] && cloned[1] != cloned[2], true)
+	assert.Equal(t, stats.consumed, 1)
+}
@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
 	"strings"
 	"time"
 
@@ -38,14 +37,63 @@ type parameters struct {
 	hasher          string
 	compressionType string
 	timeout         int
+	keyEquals       string
+	keyPrefix       string
+	keyRegex        string
+	headerFilters   []string
+	jsonFilters     []string
+	transforms      []string
+	scripts         []string
+	sourceRegistry  string
+	targetRegistry  string
+	registryKeys    bool
+	redactRules     []string
+	redactKey       string
+	redactSecret    string
+
+	preservePartitions bool
+	preserveTimestamps bool
+
+	dryRun        bool
+	dryRunSamples int
+
+	maxMessagesPerSec float64
+	maxBytesPerSec    float64
+
+	workers   int
+	producers int
+	batchSize int
+
+	copies int
+
+	onProduceError  string
+	produceRetries  int
+	retryBackoff    time.Duration
+	deadLetterTopic string
+	deadLetterFile  string
+
+	onOversized string
+
+	partitionByJSON   string
+	partitionByHeader string
+	rewriteKey        bool
+
+	offsetMap string
+
+	compact        bool
+	dropTombstones bool
+	compactionDir  string
 }
 
 var (
 	params                   parameters
 	consumerGroup            = "kafka-topic-cloner"
-	possibleHashers          = []string{"murmur2", "FNV-1a"}
+	possibleHashers          = []string{"murmur2", "FNV-1a", "crc32", "consistent_random", "round-robin", "random", "sticky"}
 	possibleCompressionTypes = []string{"none", "gzip", "snappy", "lz4"}
 
+	//keyHashers place the messages with a key by hashing it, consistent_random and sticky placing them like crc32 and murmur2
+	keyHashers = []string{"murmur2", "FNV-1a", "crc32"}
+
 	errMissingSourceTopic     = errors.New("source topic must be set")
 	errMissingTargetTopic     = errors.New("target topic must be set")
 	errLoopCloningWithTarget  = errors.New("do not specify target topic when loop-cloning")
@@ -54,6 +102,10 @@ var (
 	errSourceBrokersIsTarget  = errors.New("source and target brokers are identical")
 	errUnknownHasher          = errors.New("unknown hasher, see help for possible value")
 	errUnknownCompressionType = errors.New("unknown compression type, see help for possible value")
+	errIncompleteRegistries   = errors.New("source and target schema registries must be set together")
+	errInvalidParallelism     = errors.New("workers, producers and batch size must be at least 1")
+	errInvalidCopies          = errors.New("copies must be at least 1")
+	errMissingRedactSecret    = errors.New("redact secret must be set when redacting")
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -67,7 +119,9 @@ var rootCmd = &cobra.Command{
 	Cloning between two different clusters can be achieved by using the --to-cluster flag.
 
 	Same-topic cloning (also called loop-cloning) is protected by the --loop flag. In this case, the source topic (--from) will be used as both source and target.
-	This can be a risky operation since it will multiply the messages in the source topic until manual interruption, use with caution!
+	Only the messages present when the cloning starts are cloned, once or --copies times, the cloned messages being never cloned again.
+
+	With --compact, only the latest message of every key present when the cloning starts is cloned, the tombstones being dropped with --drop-tombstones.
 	`,
 	Run: Clone,
 }
@@ -88,12 +142,45 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&params.toBrokers, "to-brokers", "T", "", "address of the target kafka brokers, semicolon-separated (specify only if different from the source brokers)")
 	rootCmd.PersistentFlags().StringVarP(&params.fromTopic, "from", "f", "", "source topic")
 	rootCmd.PersistentFlags().StringVarP(&params.toTopic, "to", "t", "", "target topic")
-	rootCmd.PersistentFlags().StringVarP(&params.hasher, "hasher", "p", "murmur2", "partitioning hasher (possible values: murmur2, FNV-1a")
+	rootCmd.PersistentFlags().StringVarP(&params.hasher, "hasher", "p", "murmur2", fmt.Sprintf("partitioning hasher (possible values: %s)", strings.Join(possibleHashers, ", ")))
 	rootCmd.PersistentFlags().StringVarP(&params.compressionType, "compression", "c", "gzip", "producer's compression policy (possible values: none, gzip, FNV-1a")
-	rootCmd.PersistentFlags().IntVarP(&params.timeout, "timeout", "o", 10000, "delay (ms) before exiting after the last message has been cloned")
-
-	rootCmd.MarkPersistentFlagRequired("from-brokers")
-	rootCmd.MarkPersistentFlagRequired("from")
+	rootCmd.PersistentFlags().BoolVar(&params.preservePartitions, "preserve-partitions", false, "produce the messages on their source partition instead of using the hasher")
+	rootCmd.PersistentFlags().BoolVar(&params.preserveTimestamps, "preserve-timestamps", false, "produce the messages with their source timestamp instead of the current time")
+	rootCmd.PersistentFlags().StringVar(&params.partitionByJSON, "partition-by-json", "", "partition the messages by hashing this field of their JSON value (e.g. $.customerId) instead of their key")
+	rootCmd.PersistentFlags().StringVar(&params.partitionByHeader, "partition-by-header", "", "partition the messages by hashing this header instead of their key")
+	rootCmd.PersistentFlags().BoolVar(&params.rewriteKey, "rewrite-key", false, "replace the key of the messages by the field they are partitioned on")
+	rootCmd.PersistentFlags().BoolVar(&params.dryRun, "dry-run", false, "process the messages without producing them, and print what would have been produced on each target partition")
+	rootCmd.PersistentFlags().IntVar(&params.dryRunSamples, "dry-run-samples", 5, "number of processed messages printed in dry-run mode")
+	rootCmd.PersistentFlags().Float64Var(&params.maxMessagesPerSec, "max-messages-per-sec", 0, "maximum number of messages produced per second, 0 for no limit (SIGUSR1 halves the limits, SIGUSR2 doubles them)")
+	rootCmd.PersistentFlags().Float64Var(&params.maxBytesPerSec, "max-bytes-per-sec", 0, "maximum number of bytes (keys, values and headers) produced per second, 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&params.workers, "workers", 1, "number of messages processed in parallel, the messages of a source partition being processed in order")
+	rootCmd.PersistentFlags().IntVar(&params.producers, "producers", 1, "number of producers sending in parallel, the messages of a target partition being produced in order")
+	rootCmd.PersistentFlags().IntVar(&params.batchSize, "batch-size", 500, "maximum number of consumed messages handed over to the workers at once")
+	rootCmd.PersistentFlags().IntVar(&params.copies, "copies", 1, "number of times every message is produced, e.g. to multiply the messages of a topic when loop-cloning")
+	rootCmd.PersistentFlags().StringVar(&params.onProduceError, "on-produce-error", skipPolicy, fmt.Sprintf("policy applied to the messages that could not be produced once the retries are exhausted (possible values: %s)", strings.Join(possibleFailurePolicies, ", ")))
+	rootCmd.PersistentFlags().IntVar(&params.produceRetries, "produce-retries", 0, "number of times a message that could not be produced is sent again, on top of the retries of the producer, after the messages sent meanwhile")
+	rootCmd.PersistentFlags().DurationVar(&params.retryBackoff, "retry-backoff", time.Second, "delay before the first retry of a message, doubled after each retry")
+	rootCmd.PersistentFlags().StringVar(&params.deadLetterTopic, "dead-letter-topic", "", "topic of the target cluster receiving the messages that could not be produced, with the dead-letter policy")
+	rootCmd.PersistentFlags().StringVar(&params.deadLetterFile, "dead-letter-file", "", "JSON Lines file receiving the messages that could not be produced, with the dead-letter policy")
+	rootCmd.PersistentFlags().BoolVar(&params.compact, "compact", false, "only clone the latest message of every key of each partition, among the messages present when the cloning starts")
+	rootCmd.PersistentFlags().BoolVar(&params.dropTombstones, "drop-tombstones", false, "drop the latest messages which are tombstones (null value) when compacting, instead of cloning them")
+	rootCmd.PersistentFlags().StringVar(&params.compactionDir, "compaction-dir", "", "directory where the keys are indexed when compacting, the default temporary directory by default")
+	rootCmd.PersistentFlags().StringVar(&params.offsetMap, "offset-map", "", "CSV file where the source partition and offset of every produced message are recorded along with its target ones, see translate-offsets")
+	rootCmd.PersistentFlags().StringVar(&params.onOversized, "on-oversized", oversizedSkip, fmt.Sprintf("policy applied to the messages larger than the max.message.bytes of the target topic (possible values: %s)", strings.Join(possibleOversizedPolicies, ", ")))
+	rootCmd.PersistentFlags().IntVarP(&params.timeout, "timeout", "o", 10000, "delay (ms) before exiting after the last message has been cloned, 0 to never exit")
+	rootCmd.PersistentFlags().StringVar(&params.keyEquals, "key-equals", "", "only clone the messages with this exact key")
+	rootCmd.PersistentFlags().StringVar(&params.keyPrefix, "key-prefix", "", "only clone the messages whose key starts with this prefix")
+	rootCmd.PersistentFlags().StringVar(&params.keyRegex, "key-regex", "", "only clone the messages whose key matches this regular expression")
+	rootCmd.PersistentFlags().StringArrayVar(&params.headerFilters, "header", nil, "only clone the messages carrying this header (name or name=value, repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&params.jsonFilters, "json", nil, "only clone the messages whose JSON value satisfies this predicate (path, path=value or path!=value, repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&params.transforms, "transform", nil, fmt.Sprintf("transformation applied to the messages before producing them (name or name:argument, repeatable, possible names: %s)", strings.Join(kafka.Transformers(), ", ")))
+	rootCmd.PersistentFlags().StringArrayVar(&params.scripts, "script", nil, "Starlark script defining a transform(record) function, applied after the transformations (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&params.sourceRegistry, "source-registry", "", "URL of the source schema registry, enables the remapping of the schema IDs")
+	rootCmd.PersistentFlags().StringVar(&params.targetRegistry, "target-registry", "", "URL of the target schema registry, where the schemas are copied")
+	rootCmd.PersistentFlags().BoolVar(&params.registryKeys, "registry-keys", false, "remap the schema IDs of the keys as well as the values")
+	rootCmd.PersistentFlags().StringArrayVar(&params.redactRules, "redact", nil, "redaction rule applied to the JSON values (path:action, repeatable, possible actions: hash, null, fake-email, fake-name, fake-card)")
+	rootCmd.PersistentFlags().StringVar(&params.redactKey, "redact-key", "", "redaction action applied to the keys (possible values: hash, fake-email, fake-name, fake-card)")
+	rootCmd.PersistentFlags().StringVar(&params.redactSecret, "redact-secret", "", "secret used to key the redaction hashes, required when redacting")
 }
 
 //Clone handles the consuming / producing process
@@ -104,66 +191,119 @@ func Clone(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if err := clone(nil); err != nil {
+		log.Print(err)
+	}
+}
+
+//clone clones the source topic into the target topic, calling observe with every message produced, or which would have been
+//in dry-run mode, if it is not nil. The application exits if messages were lost.
+func clone(observe func(*sarama.ProducerMessage)) error {
+	stats := newSummary()
+	pipe, err := params.buildPipeline(stats)
+	if err != nil {
+		return err
+	}
+
 	fromBrokers, toBrokers := getBrokers()
 
+	//The bound is taken before producing anything, so that the cloned messages are out of it
+	var bound *loopBound
+	var compacted *compaction
+	if params.loop || params.compact {
+		marks, err := kafka.TopicWatermarks(fromBrokers, params.fromTopic)
+		if err != nil {
+			return err
+		}
+		bound = newLoopBound(marks)
+		if params.loop {
+			log.Printf("loop-cloning the %d messages of %s, %d time(s)", remaining(marks), params.fromTopic, params.copies)
+		}
+		if compacted, err = params.buildCompaction(fromBrokers, marks); err != nil {
+			return err
+		}
+		stopWatching, err := bound.watchTopic(fromBrokers, params.fromTopic)
+		if err != nil {
+			return err
+		}
+		defer stopWatching()
+	}
+
 	consumer := kafka.NewConsumer(params.fromTopic, fromBrokers, consumerGroup)
 	if params.verbose {
 		log.Printf("consumer (group: %s) initialized on %s/%s", consumerGroup, fromBrokers, params.fromTopic)
 	}
 
-	producer := kafka.NewProducer(toBrokers, params.hasher, params.compressionType)
-	if params.verbose {
-		log.Printf("producer initialized on %s/%s, hasher: %s", toBrokers, params.toTopic, params.hasher)
+	fails, err := params.buildFailures(toBrokers)
+	if err != nil {
+		return err
+	}
+	sized := newOversized(params.onOversized, func(topic string) (int, error) {
+		return kafka.MaxMessageBytes(toBrokers, topic)
+	})
+	fields, err := params.buildFieldPartitioning(toBrokers)
+	if err != nil {
+		return err
 	}
+	mapping, err := params.openOffsetMap()
+	if err != nil {
+		return err
+	}
+	var onSuccess func(*sarama.ProducerMessage)
+	switch {
+	case mapping != nil && observe != nil:
+		onSuccess = func(msg *sarama.ProducerMessage) {
+			mapping.record(msg)
+			observe(msg)
+		}
+	case mapping != nil:
+		onSuccess = mapping.record
+	case observe != nil:
+		onSuccess = observe
+	}
+	pipe.sources = onSuccess != nil
+	send, closeSink := newSink(toBrokers, fails, sized, fields, onSuccess)
 
-	//Try to gracefully shutdown
+	//Try to gracefully shutdown, the produce failures being known once the producer is closed
 	defer func() {
-		if err := producer.Close(); err != nil {
-			log.Fatal(err)
+		closeSink()
+		if mapping != nil {
+			if err := mapping.close(); err != nil {
+				log.Fatal(err)
+			}
 		}
 		if err := consumer.Close(); err != nil {
 			log.Fatal(err)
 		}
+		fails.count(stats)
+		sized.count(stats)
+		fields.count(stats)
+		if compacted != nil {
+			compacted.count(stats)
+		}
+		stats.print()
+		stats.exitOnLoss()
 	}()
 
-	//Capture interrupt and kill signal to stop the application
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, os.Kill)
-
 	//Cloning loop
-Loop:
-	for {
-		select {
-
-		case msgC, ok := <-consumer.Messages():
-			if params.verbose {
-				log.Print(fmt.Sprintf("message consumed at partition %v, offset %v", msgC.Partition, msgC.Offset))
+	w := startWorkers(params.workers, pipe, nil, send)
+	if bound == nil {
+		consumeBatches(consumer.Messages(), params.batchSize, nil, w.dispatchBatch)
+	} else {
+		consumeBatches(consumer.Messages(), params.batchSize, bound.done, func(batch []*sarama.ConsumerMessage) {
+			batch = bound.trim(batch)
+			if compacted != nil {
+				batch = compacted.trim(batch)
 			}
-			if ok {
-				msgP := &sarama.ProducerMessage{
-					Topic: params.toTopic,
-				}
-				if msgC.Value != nil {
-					msgP.Value = sarama.ByteEncoder(msgC.Value)
-				}
-				if msgC.Key != nil {
-					msgP.Key = sarama.ByteEncoder(msgC.Key)
-				}
-				producer.Input() <- msgP
-				if params.verbose {
-					log.Print("message produced")
-				}
+			if len(batch) > 0 {
+				w.dispatchBatch(batch)
+				return
 			}
-
-		case <-signals:
-			log.Print("terminating application")
-			break Loop
-
-		case <-time.After(time.Duration(params.timeout) * time.Millisecond):
-			log.Print("timeout - end of cloning")
-			break Loop
-		}
+			putBatch(batch)
+		})
 	}
+	w.stop(stats)
+	return nil
 }
 
 func (p parameters) validate() error {
@@ -193,10 +333,107 @@ func (p parameters) validate() error {
 	case !contains(possibleCompressionTypes, p.compressionType):
 		return errUnknownCompressionType
 
+	case (p.sourceRegistry == "") != (p.targetRegistry == ""):
+		return errIncompleteRegistries
+
+	case p.workers < 1 || p.producers < 1 || p.batchSize < 1:
+		return errInvalidParallelism
+
+	case p.copies < 1:
+		return errInvalidCopies
+
+	case p.dropTombstones && !p.compact:
+		return errTombstonesWithoutCompaction
+
+	}
+	return p.validateProducing()
+}
+
+//targetTopic returns the topic the messages are produced to, the source topic when loop-cloning
+func (p parameters) targetTopic() string {
+	if p.loop {
+		return p.fromTopic
+	}
+	return p.toTopic
+}
+
+//validateProducing validates the parameters shared by the commands producing messages
+func (p parameters) validateProducing() error {
+	switch true {
+
+	case p.partitionByJSON != "" && (p.partitionByHeader != "" || p.preservePartitions || !contains(keyHashers, p.hasher)):
+		return errInvalidPartitionField
+
+	case p.partitionByHeader != "" && (p.preservePartitions || !contains(keyHashers, p.hasher)):
+		return errInvalidPartitionField
+
+	case p.rewriteKey && p.partitionByJSON == "" && p.partitionByHeader == "":
+		return errRewriteKeyWithoutField
+
+	case !contains(possibleFailurePolicies, p.onProduceError):
+		return errUnknownFailurePolicy
+
+	case p.onProduceError == deadLetterPolicy && p.deadLetterTopic == "" && p.deadLetterFile == "":
+		return errMissingDeadLetter
+
+	case p.produceRetries < 0 || p.retryBackoff < 0:
+		return errInvalidRetries
+
+	case p.produceRetries > 0 && (p.producers > 1 || p.onOversized == oversizedSplit):
+		return errUnorderedRetries
+
+	case !contains(possibleOversizedPolicies, p.onOversized):
+		return errUnknownOversizedPolicy
+
+	case (len(p.redactRules) > 0 || p.redactKey != "") && p.redactSecret == "":
+		return errMissingRedactSecret
+
 	}
 	return nil
 }
 
+func (p parameters) buildFilters() (kafka.Filters, error) {
+	var filters kafka.Filters
+
+	if p.keyEquals != "" {
+		filters = append(filters, kafka.NewKeyEqualsFilter(p.keyEquals))
+	}
+	if p.keyPrefix != "" {
+		filters = append(filters, kafka.NewKeyPrefixFilter(p.keyPrefix))
+	}
+	if p.keyRegex != "" {
+		filter, err := kafka.NewKeyRegexFilter(p.keyRegex)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	for _, expr := range p.headerFilters {
+		filter, err := kafka.NewHeaderFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	for _, expr := range p.jsonFilters {
+		filter, err := kafka.NewJSONFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+//producerHasher returns the hasher given to the producer, the source partitions being kept when they have to be preserved,
+//and the partitions computed before sending when partitioning by a field, or when the messages may be split
+func (p parameters) producerHasher() string {
+	if p.preservePartitions || p.partitionByJSON != "" || p.partitionByHeader != "" || p.onOversized == oversizedSplit {
+		return kafka.ManualPartitioning
+	}
+	return p.hasher
+}
+
 func getBrokers() (from, to []string) {
 	from = strings.Split(params.fromBrokers, ";")
 
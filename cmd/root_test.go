@@ -21,6 +21,12 @@ var parametersTestCases = []parametersTest{
 			toTopic:         "foobar",
 			hasher:          "murmur2",
 			compressionType: "gzip",
+			workers:         4,
+			producers:       2,
+			batchSize:       100,
+			copies:          1,
+			onProduceError:  "skip",
+			onOversized:     "skip",
 		},
 		expected: nil,
 	},
@@ -103,6 +109,290 @@ var parametersTestCases = []parametersTest{
 		},
 		expected: errUnknownCompressionType,
 	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			sourceRegistry:  "http://localhost:8081",
+		},
+		expected: errIncompleteRegistries,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			producers:       1,
+		},
+		expected: errInvalidParallelism,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "ignore",
+		},
+		expected: errUnknownFailurePolicy,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "dead-letter",
+		},
+		expected: errMissingDeadLetter,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "fail",
+			produceRetries:  -1,
+		},
+		expected: errInvalidRetries,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       4,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "fail",
+			produceRetries:  3,
+			onOversized:     "skip",
+		},
+		expected: errUnorderedRetries,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "fail",
+			produceRetries:  3,
+			onOversized:     "split",
+		},
+		expected: errUnorderedRetries,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "fail",
+			onOversized:     "skip",
+			redactRules:     []string{"email:hash"},
+		},
+		expected: errMissingRedactSecret,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "fail",
+			onOversized:     "skip",
+			redactKey:       "hash",
+		},
+		expected: errMissingRedactSecret,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "skip",
+			onOversized:     "truncate",
+		},
+		expected: errUnknownOversizedPolicy,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "round-robin",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "skip",
+			onOversized:     "skip",
+			partitionByJSON: "$.customerId",
+		},
+		expected: errInvalidPartitionField,
+	},
+	{
+		params: parameters{
+			fromBrokers:       "foo",
+			fromTopic:         "bar",
+			toTopic:           "foobar",
+			hasher:            "murmur2",
+			compressionType:   "gzip",
+			workers:           1,
+			producers:         1,
+			batchSize:         1,
+			copies:            1,
+			onProduceError:    "skip",
+			onOversized:       "skip",
+			partitionByHeader: "customer",
+			rewriteKey:        true,
+		},
+		expected: nil,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "skip",
+			onOversized:     "skip",
+			rewriteKey:      true,
+		},
+		expected: errRewriteKeyWithoutField,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			onProduceError:  "dead-letter",
+			deadLetterFile:  "failed.jsonl",
+			produceRetries:  3,
+			onOversized:     "skip",
+		},
+		expected: nil,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+		},
+		expected: errInvalidCopies,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			loop:            true,
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          3,
+			onProduceError:  "skip",
+			onOversized:     "skip",
+		},
+		expected: nil,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			compact:         true,
+			dropTombstones:  true,
+			onProduceError:  "skip",
+			onOversized:     "skip",
+		},
+		expected: nil,
+	},
+	{
+		params: parameters{
+			fromBrokers:     "foo",
+			fromTopic:       "bar",
+			toTopic:         "foobar",
+			hasher:          "murmur2",
+			compressionType: "gzip",
+			workers:         1,
+			producers:       1,
+			batchSize:       1,
+			copies:          1,
+			dropTombstones:  true,
+		},
+		expected: errTombstonesWithoutCompaction,
+	},
 }
 
 func TestValidateParameters(t *testing.T) {
@@ -168,3 +458,30 @@ func TestContains(t *testing.T) {
 		assert.Equal(t, actual, v.expected)
 	}
 }
+
+func TestBuildFilters(t *testing.T) {
+	//Arrange
+	p := parameters{
+		keyPrefix:     "foo",
+		headerFilters: []string{"type=created"},
+		jsonFilters:   []string{"customer.id=42"},
+	}
+
+	//Act
+	filters, err := p.buildFilters()
+
+	//Assert
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(filters), 3)
+}
+
+func TestBuildFiltersInvalidRegex(t *testing.T) {
+	//Arrange
+	p := parameters{keyRegex: "("}
+
+	//Act
+	_, err := p.buildFilters()
+
+	//Assert
+	assert.Equal(t, err != nil, true)
+}
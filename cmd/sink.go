@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//newSink returns where the processed messages are sent: the producer, or the dry-run report when nothing must be produced.
+//close flushes the producer, or prints the report. The messages are partitioned on their field by fields if needed, the
+//messages larger than their topic accepts are handled by sized, and the messages that could not be produced are handed to fails.
+//onSuccess is called with every message produced, or added to the dry-run report with its partition, if it is not nil.
+func newSink(toBrokers []string, fails *failures, sized *oversized, fields *fieldPartitioning, onSuccess func(*sarama.ProducerMessage)) (send func(*sarama.ProducerMessage), close func()) {
+	if params.dryRun {
+		dry := newDryRun(params.producerHasher(), params.dryRunSamples, func(topic string) (int32, error) {
+			return kafka.PartitionCount(toBrokers, topic)
+		})
+		dry.onAdd = onSuccess
+		log.Printf("dry run, nothing will be produced on %s", toBrokers)
+		return fields.wrap(sized.wrap(dry.add)), dry.print
+	}
+
+	//The batches of the producer must fit in the target topic, the topics set by scripts being assumed to accept as much
+	var maxMessageBytes int
+	if topic := params.targetTopic(); topic != "" {
+		maxMessageBytes = sized.maxMessageBytes(topic)
+		if params.verbose {
+			log.Printf("max.message.bytes of %s: %d", topic, maxMessageBytes)
+		}
+	}
+
+	limits := newThrottle(params.maxMessagesPerSec, params.maxBytesPerSec)
+	if params.maxMessagesPerSec > 0 || params.maxBytesPerSec > 0 {
+		log.Printf("throttling, %s", limits)
+		notifyThrottle(limits)
+	}
+
+	if params.producers > 1 {
+		send, close = newShardedSink(toBrokers, maxMessageBytes, limits, fails, onSuccess)
+		return fields.wrap(sized.wrap(send)), close
+	}
+
+	producer := kafka.NewProducer(toBrokers, params.producerHasher(), params.compressionType, maxMessageBytes, fails.handle, onSuccess)
+	if params.verbose {
+		log.Printf("producer initialized on %s/%s, hasher: %s", toBrokers, params.targetTopic(), params.producerHasher())
+	}
+
+	send = func(msgP *sarama.ProducerMessage) {
+		track(msgP)
+		limits.wait(msgP)
+		producer.Input() <- msgP
+	}
+	close = func() {
+		fails.drain()
+		if err := producer.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fails.close()
+	}
+	fails.resend = send
+	return fields.wrap(sized.wrap(send)), close
+}
+
+//newShardedSink produces through several producers, every target partition being produced by the same one to keep its order
+func newShardedSink(toBrokers []string, maxMessageBytes int, limits *throttle, fails *failures, onSuccess func(*sarama.ProducerMessage)) (send func(*sarama.ProducerMessage), close func()) {
+	producer, err := kafka.NewShardedProducer(toBrokers, params.producerHasher(), params.compressionType, maxMessageBytes, params.producers, fails.handle, onSuccess)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if params.verbose {
+		log.Printf("%d producers initialized on %s/%s, hasher: %s", params.producers, toBrokers, params.targetTopic(), params.producerHasher())
+	}
+
+	send = func(msgP *sarama.ProducerMessage) {
+		track(msgP)
+		limits.wait(msgP)
+		if err := producer.Send(msgP); err != nil {
+			fails.handle(&sarama.ProducerError{Msg: msgP, Err: err})
+		}
+	}
+	close = func() {
+		fails.drain()
+		if err := producer.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fails.close()
+	}
+	fails.resend = send
+	return
+}
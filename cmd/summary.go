@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+//summary keeps track of what happened to the consumed messages, it is printed when the cloning ends
+type summary struct {
+	consumed int
+	produced int
+	filtered map[string]int
+
+	transformErrors int
+	scriptErrors    map[string]int
+	redactionErrors int
+	schemaErrors    int
+
+	partitioningErrors int
+
+	produceFailures  int
+	deadLettered     int
+	oversizedSkipped int
+	oversizedSplit   int
+
+	superseded        int
+	tombstonesDropped int
+}
+
+func newSummary() *summary {
+	return &summary{
+		filtered:     make(map[string]int),
+		scriptErrors: make(map[string]int),
+	}
+}
+
+func (s *summary) filter(f kafka.Filter) {
+	s.filtered[f.String()]++
+}
+
+func (s *summary) scriptError(script *kafka.Script) {
+	s.scriptErrors[script.String()]++
+}
+
+//add adds the counts of another summary
+func (s *summary) add(other *summary) {
+	s.consumed += other.consumed
+	s.produced += other.produced
+	s.transformErrors += other.transformErrors
+	s.redactionErrors += other.redactionErrors
+	s.schemaErrors += other.schemaErrors
+	s.partitioningErrors += other.partitioningErrors
+	s.produceFailures += other.produceFailures
+	s.deadLettered += other.deadLettered
+	s.oversizedSkipped += other.oversizedSkipped
+	s.oversizedSplit += other.oversizedSplit
+	s.superseded += other.superseded
+	s.tombstonesDropped += other.tombstonesDropped
+	for name, n := range other.filtered {
+		s.filtered[name] += n
+	}
+	for name, n := range other.scriptErrors {
+		s.scriptErrors[name] += n
+	}
+}
+
+func (s *summary) lines() []string {
+	lines := []string{
+		fmt.Sprintf("consumed: %d", s.consumed),
+		fmt.Sprintf("produced: %d", s.produced),
+		fmt.Sprintf("transformation errors: %d", s.transformErrors),
+		fmt.Sprintf("redaction errors: %d", s.redactionErrors),
+		fmt.Sprintf("schema remapping errors: %d", s.schemaErrors),
+		fmt.Sprintf("partitioning errors: %d", s.partitioningErrors),
+		fmt.Sprintf("produce failures: %d", s.produceFailures),
+		fmt.Sprintf("dead-lettered: %d", s.deadLettered),
+		fmt.Sprintf("oversized records skipped: %d", s.oversizedSkipped),
+		fmt.Sprintf("oversized records split: %d", s.oversizedSplit),
+		fmt.Sprintf("superseded records dropped: %d", s.superseded),
+		fmt.Sprintf("tombstones dropped: %d", s.tombstonesDropped),
+	}
+
+	for _, name := range sortedKeys(s.filtered) {
+		lines = append(lines, fmt.Sprintf("filtered out by %s: %d", name, s.filtered[name]))
+	}
+	for _, name := range sortedKeys(s.scriptErrors) {
+		lines = append(lines, fmt.Sprintf("errors in script %s: %d", name, s.scriptErrors[name]))
+	}
+	return lines
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *summary) print() {
+	log.Printf("summary:\n\t%s", strings.Join(s.lines(), "\n\t"))
+}
+
+//exitOnLoss exits with a non-zero status when messages could not be produced, even if they were dead-lettered, or were
+//skipped for being too large or lacking their partitioning field
+func (s *summary) exitOnLoss() {
+	if n := s.lost(); n > 0 {
+		log.Printf("%d messages could not be produced", n)
+		os.Exit(1)
+	}
+}
+
+//lost returns the number of messages which should have been produced but were not
+func (s *summary) lost() int {
+	return s.produceFailures + s.oversizedSkipped + s.partitioningErrors
+}
@@ -0,0 +1,65 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+func TestSummaryLines(t *testing.T) {
+	//Arrange
+	s := newSummary()
+	s.consumed = 5
+	s.produced = 2
+	s.transformErrors = 1
+	s.scriptErrors["split.star"] = 3
+	s.filter(kafka.NewKeyPrefixFilter("foo"))
+	s.filter(kafka.NewKeyEqualsFilter("bar"))
+	s.filter(kafka.NewKeyPrefixFilter("foo"))
+	expected := []string{
+		"consumed: 5",
+		"produced: 2",
+		"transformation errors: 1",
+		"redaction errors: 0",
+		"schema remapping errors: 0",
+		"partitioning errors: 0",
+		"produce failures: 0",
+		"dead-lettered: 0",
+		"oversized records skipped: 0",
+		"oversized records split: 0",
+		"superseded records dropped: 0",
+		"tombstones dropped: 0",
+		"filtered out by key-equals(bar): 1",
+		"filtered out by key-prefix(foo): 2",
+		"errors in script split.star: 3",
+	}
+
+	//Act
+	actual := s.lines()
+
+	//Assert
+	assert.Equal(t, actual, expected)
+}
+
+func TestSummaryLost(t *testing.T) {
+	tests := []struct {
+		summary  summary
+		expected int
+	}{
+		{summary: summary{consumed: 5, produced: 5}, expected: 0},
+		{summary: summary{deadLettered: 1, produceFailures: 1}, expected: 1},
+		{summary: summary{oversizedSkipped: 2, oversizedSplit: 1}, expected: 2},
+		{summary: summary{partitioningErrors: 3}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := tt.summary.lost()
+
+		//Assert
+		assert.Equal(t, actual, tt.expected)
+	}
+}
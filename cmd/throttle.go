@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+//tokenBucket limits a rate of tokens per second, allowing bursts of up to a second worth of tokens.
+//A take larger than the bucket is allowed, the bucket going into debt, so that a large message is delayed rather than blocked forever.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+//newTokenBucket returns a bucket filled at rate tokens per second, 0 meaning unlimited
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, now: time.Now, sleep: time.Sleep}
+}
+
+//take waits until n tokens are available
+func (b *tokenBucket) take(n float64) {
+	b.mu.Lock()
+	if b.rate <= 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+	b.last = now
+	b.tokens -= n
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait > 0 {
+		b.sleep(wait)
+	}
+}
+
+//scale multiplies the rate, an unlimited bucket staying unlimited
+func (b *tokenBucket) scale(factor float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate *= factor
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+func (b *tokenBucket) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.0f", b.rate)
+}
+
+//throttle limits the messages and bytes produced per second
+type throttle struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newThrottle(messagesPerSec, bytesPerSec float64) *throttle {
+	return &throttle{messages: newTokenBucket(messagesPerSec), bytes: newTokenBucket(bytesPerSec)}
+}
+
+//wait blocks until the message can be produced
+func (t *throttle) wait(msg *sarama.ProducerMessage) {
+	t.messages.take(1)
+	t.bytes.take(float64(messageSize(msg)))
+}
+
+//scale multiplies both limits, to adjust them while cloning
+func (t *throttle) scale(factor float64) {
+	t.messages.scale(factor)
+	t.bytes.scale(factor)
+}
+
+func (t *throttle) String() string {
+	return fmt.Sprintf("messages/sec: %s, bytes/sec: %s", t.messages, t.bytes)
+}
+
+//messageSize is the size of the key, value and headers of a message
+func messageSize(msg *sarama.ProducerMessage) int {
+	size := encoderLength(msg.Key) + encoderLength(msg.Value)
+	for _, h := range msg.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
+
+func encoderLength(e sarama.Encoder) int {
+	if e == nil {
+		return 0
+	}
+	return e.Length()
+}
@@ -0,0 +1,83 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+//fakeClock makes the bucket sleep instantly, recording the sleeps
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) bucket(rate float64) *tokenBucket {
+	b := newTokenBucket(rate)
+	b.now = func() time.Time { return c.now }
+	b.sleep = func(d time.Duration) {
+		c.sleeps = append(c.sleeps, d)
+		c.now = c.now.Add(d)
+	}
+	return b
+}
+
+func TestTokenBucket(t *testing.T) {
+	//Arrange
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := clock.bucket(10)
+
+	//Act
+	for i := 0; i < 12; i++ {
+		b.take(1)
+	}
+	clock.now = clock.now.Add(time.Second)
+	b.take(5)
+	b.take(30)
+
+	//Assert
+	assert.Equal(t, clock.sleeps, []time.Duration{
+		100 * time.Millisecond,
+		100 * time.Millisecond,
+		2500 * time.Millisecond,
+	})
+}
+
+func TestTokenBucketScale(t *testing.T) {
+	//Arrange
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limited, unlimited := clock.bucket(10), clock.bucket(0)
+
+	//Act
+	limited.scale(0.5)
+	unlimited.scale(2)
+	for i := 0; i < 6; i++ {
+		limited.take(1)
+		unlimited.take(1000)
+	}
+
+	//Assert
+	assert.Equal(t, clock.sleeps, []time.Duration{200 * time.Millisecond})
+	assert.Equal(t, limited.String(), "5")
+	assert.Equal(t, unlimited.String(), "unlimited")
+}
+
+func TestMessageSize(t *testing.T) {
+	//Arrange
+	msg := &sarama.ProducerMessage{
+		Key:     sarama.StringEncoder("foo"),
+		Value:   sarama.ByteEncoder("value"),
+		Headers: []sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}},
+	}
+
+	//Act
+	actual := messageSize(msg)
+
+	//Assert
+	assert.Equal(t, actual, 10)
+	assert.Equal(t, messageSize(&sarama.ProducerMessage{}), 0)
+}
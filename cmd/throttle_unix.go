@@ -0,0 +1,27 @@
+//+build !windows
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+//notifyThrottle adjusts the limits while cloning: SIGUSR1 halves them, SIGUSR2 doubles them
+func notifyThrottle(t *throttle) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for s := range signals {
+			if s == syscall.SIGUSR1 {
+				t.scale(0.5)
+			} else {
+				t.scale(2)
+			}
+			log.Printf("throttling adjusted, %s", t)
+		}
+	}()
+}
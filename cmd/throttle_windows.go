@@ -0,0 +1,4 @@
+package cmd
+
+//notifyThrottle is a no-op, there are no user signals on windows
+func notifyThrottle(t *throttle) {}
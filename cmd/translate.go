@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+type translateParameters struct {
+	group       string
+	targetGroup string
+}
+
+var (
+	translateParams translateParameters
+
+	errMissingOffsetMap = errors.New("offset map must be set")
+	errEmptyTranslation = errors.New("the offset map holds no message produced in the target topic")
+)
+
+var translateCmd = &cobra.Command{
+	Use:   "translate-offsets --from-brokers [url] --to-brokers [url] --from [source] --to [target] --offset-map [file] --group [group]",
+	Short: "Commit the offsets of a consumer group on the target topic of a clone",
+	Long: `
+	Translate-offsets reads the offsets committed by a consumer group on the source topic, and commits the equivalent offsets
+	for the group on the target topic, using the offset map recorded while cloning or mirroring (--offset-map).
+
+	A target partition gets the offset of its first event produced from a source event the group has not consumed yet, or the
+	offset following its last event when the group consumed them all: the group may consume some events again, but misses none.
+	The source partitions without committed offset are assumed not consumed at all, and only the target partitions found in
+	the offset map get an offset.
+
+	The consumers of the group must be stopped on the target cluster, the coordinator rejecting the commits otherwise.
+	With --dry-run, the offsets are printed without being committed.
+	`,
+	Run: TranslateOffsets,
+}
+
+func init() {
+	rootCmd.AddCommand(translateCmd)
+
+	translateCmd.Flags().StringVar(&translateParams.group, "group", "", "consumer group whose offsets on the source topic are translated")
+	translateCmd.Flags().StringVar(&translateParams.targetGroup, "target-group", "", "consumer group committing the translated offsets, the same group by default")
+}
+
+//TranslateOffsets handles the translating / committing process
+func TranslateOffsets(cmd *cobra.Command, args []string) {
+
+	if err := translateParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	fromBrokers, toBrokers := getBrokers()
+	offsets, err := translateParams.translate(fromBrokers)
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	group := translateParams.target()
+	log.Printf("offsets of %s on %s:\n\t%s", group, params.toTopic, strings.Join(offsetLines(offsets), "\n\t"))
+	if params.dryRun {
+		log.Print("dry run, nothing committed")
+		return
+	}
+	if err := kafka.CommitOffsets(toBrokers, group, params.toTopic, offsets); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
+
+//translate returns the offsets of the group on the target topic, from its offsets on the source topic and the offset map
+func (t translateParameters) translate(fromBrokers []string) (map[int32]int64, error) {
+	committed, err := kafka.CommittedOffsets(fromBrokers, t.group, params.fromTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(params.offsetMap)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tr := newTranslation(params.toTopic, committed)
+	if err := readOffsetMap(file, tr.add); err != nil {
+		return nil, err
+	}
+	offsets := tr.offsets()
+	if len(offsets) == 0 {
+		return nil, errEmptyTranslation
+	}
+	return offsets, nil
+}
+
+//target returns the group committing the translated offsets
+func (t translateParameters) target() string {
+	if t.targetGroup != "" {
+		return t.targetGroup
+	}
+	return t.group
+}
+
+//offsetLines formats offsets in partition order
+func offsetLines(offsets map[int32]int64) []string {
+	partitions := make([]int, 0, len(offsets))
+	for p := range offsets {
+		partitions = append(partitions, int(p))
+	}
+	sort.Ints(partitions)
+
+	lines := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		lines = append(lines, fmt.Sprintf("partition %d: %d", p, offsets[int32(p)]))
+	}
+	return lines
+}
+
+func (t translateParameters) validate(p parameters) error {
+	switch true {
+
+	case p.fromTopic == "":
+		return errMissingSourceTopic
+
+	case p.toTopic == "":
+		return errMissingTargetTopic
+
+	case p.fromBrokers == "":
+		return errMissingSourceBrokers
+
+	case p.offsetMap == "":
+		return errMissingOffsetMap
+
+	case t.group == "":
+		return errMissingGroup
+
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+//+build unit
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestValidateTranslateParameters(t *testing.T) {
+	valid := parameters{fromBrokers: "foo", toBrokers: "bar", fromTopic: "foo", toTopic: "foo", offsetMap: "foo.csv"}
+	noMap := valid
+	noMap.offsetMap = ""
+	noTarget := valid
+	noTarget.toTopic = ""
+
+	tests := []struct {
+		translate translateParameters
+		params    parameters
+		expected  error
+	}{
+		{translate: translateParameters{group: "foo"}, params: valid, expected: nil},
+		{translate: translateParameters{}, params: valid, expected: errMissingGroup},
+		{translate: translateParameters{group: "foo"}, params: noMap, expected: errMissingOffsetMap},
+		{translate: translateParameters{group: "foo"}, params: noTarget, expected: errMissingTargetTopic},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := tt.translate.validate(tt.params)
+
+		//Assert
+		assert.Equal(t, actual, tt.expected)
+	}
+}
+
+func TestTranslateTarget(t *testing.T) {
+	assert.Equal(t, translateParameters{group: "foo"}.target(), "foo")
+	assert.Equal(t, translateParameters{group: "foo", targetGroup: "bar"}.target(), "bar")
+}
+
+func TestOffsetLines(t *testing.T) {
+	assert.Equal(t, offsetLines(map[int32]int64{10: 3, 2: 0, 1: 42}), []string{"partition 1: 42", "partition 2: 0", "partition 10: 3"})
+}
@@ -0,0 +1,606 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+	"github.com/spf13/cobra"
+)
+
+//Possible comparison modes
+const (
+	verifyByKey       = "key"
+	verifyByPartition = "partition"
+)
+
+type verifyParameters struct {
+	by            string
+	ignoreHeaders bool
+}
+
+var (
+	verifyParams   verifyParameters
+	possibleVerify = []string{verifyByKey, verifyByPartition}
+
+	errUnknownVerifyMode = errors.New("unknown comparison mode, see help for possible value")
+	errVerifySameTopic   = errors.New("cannot compare a topic with itself")
+
+	errVerifyOffsetMapByKey = errors.New("the offset map can only be used to compare by partition")
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify --from-brokers [url] --from [source] --to [target]",
+	Short: "Compare the content of two topics",
+	Long: `
+	Verify consumes both the source and the target topics, and compares their events. It exits with a non-zero status when they differ.
+
+	By key, the events sharing a key are compared in the order they were read: keys can be missing or extra, have mismatched
+	events, or have the same events in a different order. This is the comparison to use when the target was re-partitioned.
+
+	By partition, each partition of the source is compared with the same partition of the target, event by event. With the
+	offset map recorded while cloning (--offset-map), every target event is compared with the source event it was cloned
+	from instead, so that a missing event does not shift the following ones. The offset map is indexed on disk beforehand,
+	in the temporary directory.
+
+	Only hashes of the events are kept, so that large topics can be compared: a few dozen bytes per key, and by partition
+	only the events read from one topic but not yet from the other, up to 65536 per partition. Beyond it, the oldest are
+	dropped, and the events they would be compared with are counted as unchecked. Offsets and timestamps are not compared.
+	`,
+	Run: Verify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVar(&verifyParams.by, "by", verifyByKey, fmt.Sprintf("comparison mode (possible values: %s)", strings.Join(possibleVerify, ", ")))
+	verifyCmd.Flags().BoolVar(&verifyParams.ignoreHeaders, "ignore-headers", false, "do not compare the headers of the events")
+}
+
+//Verify handles the consuming / comparing process
+func Verify(cmd *cobra.Command, args []string) {
+
+	if err := verifyParams.validate(params); err != nil {
+		log.Print(err)
+		return
+	}
+
+	fromBrokers, toBrokers := getBrokers()
+
+	//Distinct groups, so that each consumer gets every partition of its topic
+	source := kafka.NewConsumer(params.fromTopic, fromBrokers, consumerGroup+"-verify-source")
+	target := kafka.NewConsumer(params.toTopic, toBrokers, consumerGroup+"-verify-target")
+	if params.verbose {
+		log.Printf("consumers initialized on %s/%s and %s/%s", fromBrokers, params.fromTopic, toBrokers, params.toTopic)
+	}
+
+	var sources *sourceIndex
+	if params.offsetMap != "" {
+		var err error
+		if sources, err = openSourceIndex(params.offsetMap, params.toTopic); err != nil {
+			log.Print(err)
+			return
+		}
+		defer sources.close()
+	}
+	c := verifyParams.newComparison(sources)
+	consumeBoth(source.Messages(), target.Messages(), nil, c.add)
+
+	//Try to gracefully shutdown
+	if err := source.Close(); err != nil {
+		log.Print(err)
+	}
+	if err := target.Close(); err != nil {
+		log.Print(err)
+	}
+
+	log.Printf("verification:\n\t%s", strings.Join(c.lines(), "\n\t"))
+	if sources != nil && sources.err != nil {
+		log.Printf("cannot read the offset map index: %v", sources.err)
+	}
+	if c.differs() {
+		sources.close()
+		os.Exit(1)
+	}
+}
+
+func (v verifyParameters) validate(p parameters) error {
+	switch true {
+
+	case p.fromTopic == "":
+		return errMissingSourceTopic
+
+	case p.toTopic == "":
+		return errMissingTargetTopic
+
+	case p.fromBrokers == "":
+		return errMissingSourceBrokers
+
+	case p.fromTopic == p.toTopic && (p.toBrokers == "" || p.toBrokers == p.fromBrokers):
+		return errVerifySameTopic
+
+	case !contains(possibleVerify, v.by):
+		return errUnknownVerifyMode
+
+	case p.offsetMap != "" && v.by != verifyByPartition:
+		return errVerifyOffsetMapByKey
+
+	}
+	return nil
+}
+
+//comparison accumulates the hashes of the events of both topics
+type comparison interface {
+	add(side int, msg *sarama.ConsumerMessage)
+	lines() []string
+	differs() bool
+}
+
+//newComparison returns the comparison of the mode, sources mapping the target events to their source events when comparing by partition
+func (v verifyParameters) newComparison(sources *sourceIndex) comparison {
+	if v.by == verifyByPartition {
+		return &partitionComparison{ignoreHeaders: v.ignoreHeaders, partitions: make(map[int32]*partitionDigest), sources: sources}
+	}
+	return &keyComparison{ignoreHeaders: v.ignoreHeaders, keys: make(map[uint64]*keyDigest)}
+}
+
+//hashMessage hashes the parts of a message that are kept by a clone, each part being length-prefixed so that they cannot shift into each other
+func hashMessage(msg *sarama.ConsumerMessage, withKey, withHeaders bool) uint64 {
+	h := fnv.New64a()
+	write := func(b []byte) {
+		var size [binary.MaxVarintLen64]byte
+		if b == nil {
+			h.Write(size[:binary.PutVarint(size[:], -1)])
+			return
+		}
+		h.Write(size[:binary.PutVarint(size[:], int64(len(b)))])
+		h.Write(b)
+	}
+
+	if withKey {
+		write(msg.Key)
+	}
+	write(msg.Value)
+	if withHeaders {
+		for _, header := range msg.Headers {
+			if header != nil {
+				write(header.Key)
+				write(header.Value)
+			}
+		}
+	}
+	return h.Sum64()
+}
+
+//chain folds a hash into an order-dependent running hash
+func chain(running, h uint64) uint64 {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], running)
+	binary.BigEndian.PutUint64(b[8:], h)
+	f := fnv.New64a()
+	f.Write(b[:])
+	return f.Sum64()
+}
+
+//keyDigest sums up the events of a key on both sides: their count, an order-independent
+//hash (the sum of the event hashes) and an order-dependent one
+type keyDigest struct {
+	count   [2]int64
+	content [2]uint64
+	order   [2]uint64
+}
+
+type keyComparison struct {
+	ignoreHeaders bool
+	keys          map[uint64]*keyDigest
+	consumed      [2]int64
+}
+
+func (c *keyComparison) add(side int, msg *sarama.ConsumerMessage) {
+	c.consumed[side]++
+
+	//Null and empty keys hash differently
+	key := hashMessage(&sarama.ConsumerMessage{Value: msg.Key}, false, false)
+	d, ok := c.keys[key]
+	if !ok {
+		d = &keyDigest{}
+		c.keys[key] = d
+	}
+
+	h := hashMessage(msg, false, !c.ignoreHeaders)
+	d.count[side]++
+	d.content[side] += h
+	d.order[side] = chain(d.order[side], h)
+}
+
+//result classifies the keys: the events of a missing key are only in the source, those of an extra key
+//only in the target. The other keys differing in count are mismatched, as well as those with the same count but not the same events.
+func (c *keyComparison) result() (matching, missing, extra, mismatched, reordered int) {
+	for _, d := range c.keys {
+		switch {
+		case d.count[targetSide] == 0:
+			missing++
+		case d.count[sourceSide] == 0:
+			extra++
+		case d.count[sourceSide] != d.count[targetSide] || d.content[sourceSide] != d.content[targetSide]:
+			mismatched++
+		case d.order[sourceSide] != d.order[targetSide]:
+			reordered++
+		default:
+			matching++
+		}
+	}
+	return
+}
+
+func (c *keyComparison) lines() []string {
+	matching, missing, extra, mismatched, reordered := c.result()
+	return []string{
+		fmt.Sprintf("consumed from source: %d", c.consumed[sourceSide]),
+		fmt.Sprintf("consumed from target: %d", c.consumed[targetSide]),
+		fmt.Sprintf("matching keys: %d", matching),
+		fmt.Sprintf("keys missing from target: %d", missing),
+		fmt.Sprintf("extra keys in target: %d", extra),
+		fmt.Sprintf("keys with mismatched events: %d", mismatched),
+		fmt.Sprintf("keys with reordered events: %d", reordered),
+	}
+}
+
+func (c *keyComparison) differs() bool {
+	_, missing, extra, mismatched, reordered := c.result()
+	return missing+extra+mismatched+reordered > 0
+}
+
+//verifyMaxPending caps the hashes of a partition read from one topic but not yet from the other. Beyond it, the oldest are
+//dropped, and the events at their positions on the other side are only counted as unchecked.
+const verifyMaxPending = 1 << 16
+
+//eventPosition is the partition and offset of an event
+type eventPosition struct {
+	partition int32
+	offset    int64
+}
+
+//partitionDigest compares the events of a partition pairwise, an event being compared with the event at the same position
+//on the other side: its rank in the partition, or its source offset when the target events are mapped to their source.
+//The hashes read from one side are kept until the event at the same position is read from the other side.
+type partitionDigest struct {
+	pending [2]map[int64]uint64
+	//queued holds the positions of the pending hashes in the order they were read, so that the oldest can be dropped,
+	//dropped the position below which hashes were dropped
+	queued  [2][]int64
+	dropped [2]int64
+	//surplus counts the events read at a position already pending on the same side, e.g. a source event cloned twice
+	surplus [2]int64
+
+	count     [2]int64
+	content   [2]uint64
+	matching  int64
+	differ    int64
+	unchecked int64
+}
+
+func newPartitionDigest() *partitionDigest {
+	return &partitionDigest{pending: [2]map[int64]uint64{make(map[int64]uint64), make(map[int64]uint64)}}
+}
+
+func (d *partitionDigest) add(side int, position int64, h uint64) {
+	d.count[side]++
+	d.content[side] += h
+
+	other := 1 - side
+	theirs, ok := d.pending[other][position]
+	switch {
+	case ok:
+		delete(d.pending[other], position)
+		if theirs == h {
+			d.matching++
+		} else {
+			d.differ++
+		}
+		return
+
+	case position < d.dropped[other]:
+		d.unchecked++
+		return
+	}
+
+	if _, ok := d.pending[side][position]; ok {
+		d.surplus[side]++
+		return
+	}
+	d.pending[side][position] = h
+	d.queued[side] = append(d.queued[side], position)
+	d.trim(side)
+}
+
+//trim forgets the positions no longer pending, and drops the oldest hashes beyond verifyMaxPending
+func (d *partitionDigest) trim(side int) {
+	queued := d.queued[side]
+	for len(queued) > 0 && (len(d.pending[side]) > verifyMaxPending || !d.isPending(side, queued[0])) {
+		if d.isPending(side, queued[0]) {
+			delete(d.pending[side], queued[0])
+			if queued[0] >= d.dropped[side] {
+				d.dropped[side] = queued[0] + 1
+			}
+		}
+		queued = queued[1:]
+	}
+	//The positions matched out of order are left behind the oldest one, until they outnumber the pending ones
+	if len(queued) > 2*len(d.pending[side])+verifyMaxPending {
+		kept := make([]int64, 0, len(d.pending[side]))
+		for _, position := range queued {
+			if d.isPending(side, position) {
+				kept = append(kept, position)
+			}
+		}
+		queued = kept
+	}
+	d.queued[side] = queued
+}
+
+func (d *partitionDigest) isPending(side int, position int64) bool {
+	_, ok := d.pending[side][position]
+	return ok
+}
+
+func (d *partitionDigest) missing() int64 {
+	return int64(len(d.pending[sourceSide])) + d.surplus[sourceSide]
+}
+
+func (d *partitionDigest) extra() int64 {
+	return int64(len(d.pending[targetSide])) + d.surplus[targetSide]
+}
+
+//status tells how a partition compares: the same events in a different order are reordered. The events left unchecked
+//are only compared by the sum of their hashes.
+func (d *partitionDigest) status() string {
+	sameContent := d.count[sourceSide] == d.count[targetSide] && d.content[sourceSide] == d.content[targetSide]
+	switch {
+	case d.differ == 0 && d.missing() == 0 && d.extra() == 0 && (d.unchecked == 0 || sameContent):
+		return "identical"
+	case sameContent:
+		return "reordered"
+	}
+	return "different"
+}
+
+type partitionComparison struct {
+	ignoreHeaders bool
+	partitions    map[int32]*partitionDigest
+	//sources finds the source event of every target event, read from an offset map, nil to compare the events by rank
+	sources  *sourceIndex
+	unmapped int64
+}
+
+func (c *partitionComparison) add(side int, msg *sarama.ConsumerMessage) {
+	h := hashMessage(msg, true, !c.ignoreHeaders)
+	if c.sources == nil {
+		d := c.partition(msg.Partition)
+		d.add(side, d.count[side], h)
+		return
+	}
+
+	source := eventPosition{partition: msg.Partition, offset: msg.Offset}
+	if side == targetSide {
+		var ok bool
+		if source, ok = c.sources.source(source); !ok {
+			c.unmapped++
+			return
+		}
+	}
+	c.partition(source.partition).add(side, source.offset, h)
+}
+
+func (c *partitionComparison) partition(partition int32) *partitionDigest {
+	d, ok := c.partitions[partition]
+	if !ok {
+		d = newPartitionDigest()
+		c.partitions[partition] = d
+	}
+	return d
+}
+
+func (c *partitionComparison) lines() []string {
+	partitions := make([]int, 0, len(c.partitions))
+	for p := range c.partitions {
+		partitions = append(partitions, int(p))
+	}
+	sort.Ints(partitions)
+
+	var lines []string
+	for _, p := range partitions {
+		d := c.partitions[int32(p)]
+		lines = append(lines, fmt.Sprintf("partition %d: %s, source: %d, target: %d, matching: %d, mismatched: %d, missing from target: %d, extra in target: %d, unchecked: %d",
+			p, d.status(), d.count[sourceSide], d.count[targetSide], d.matching, d.differ, d.missing(), d.extra(), d.unchecked))
+	}
+	if c.sources != nil {
+		lines = append(lines, fmt.Sprintf("target events missing from the offset map: %d", c.unmapped))
+	}
+	return lines
+}
+
+func (c *partitionComparison) differs() bool {
+	if c.unmapped > 0 {
+		return true
+	}
+	for _, d := range c.partitions {
+		if d.status() != "identical" {
+			return true
+		}
+	}
+	return false
+}
+
+//sourceEntrySize is the size of an entry of a source index: a presence byte, the source partition and the source offset
+const sourceEntrySize = 1 + 4 + 8
+
+//sourceWindow is the number of entries of a partition read at once, the target events being read in the order of their offsets
+const sourceWindow = 4096
+
+//sourceIndex finds the source event of the target events, from an offset map indexed on disk so that large topics can be
+//compared: a file per target partition holds an entry per target offset from the lowest one of the partition, the offsets
+//missing from the offset map being left as holes. Only a window of entries per partition is loaded in memory.
+//It is not safe for concurrent use.
+type sourceIndex struct {
+	dir        string
+	partitions map[int32]*sourcePartition
+	//err is the first error met while reading the index, the following events being reported as missing from the offset map
+	err error
+}
+
+//sourcePartition is the file of the entries of a target partition
+type sourcePartition struct {
+	file  *os.File
+	first int64
+
+	//buf buffers the entries written in a row, next being the position following the last one
+	buf  *bufio.Writer
+	next int64
+
+	//window holds the entries read last, from the position start
+	window []byte
+	start  int64
+}
+
+//openSourceIndex indexes the source events of the events of the target topic found in the offset map at path
+func openSourceIndex(path, topic string) (*sourceIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return indexSources(file, topic)
+}
+
+//indexSources indexes the source events of the events of the target topic found in an offset map, in a temporary directory.
+//The offset map is read twice: to find the lowest target offset of every partition, then to write the entries.
+func indexSources(r io.ReadSeeker, topic string) (*sourceIndex, error) {
+	first := make(map[int32]int64)
+	err := readOffsetMap(r, func(m offsetMapping) {
+		if offset, ok := first[m.targetPartition]; m.targetTopic == topic && (!ok || m.targetOffset < offset) {
+			first[m.targetPartition] = m.targetOffset
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "kafka-topic-cloner-verify")
+	if err != nil {
+		return nil, err
+	}
+	x := &sourceIndex{dir: dir, partitions: make(map[int32]*sourcePartition, len(first))}
+	for partition, offset := range first {
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("partition-%d", partition)))
+		if err != nil {
+			x.close()
+			return nil, err
+		}
+		x.partitions[partition] = &sourcePartition{file: file, first: offset, buf: bufio.NewWriter(file)}
+	}
+
+	err = readOffsetMap(r, func(m offsetMapping) {
+		if m.targetTopic == topic && x.err == nil {
+			x.err = x.partitions[m.targetPartition].put(m)
+		}
+	})
+	for _, p := range x.partitions {
+		if err == nil && x.err == nil {
+			x.err = p.buf.Flush()
+		}
+	}
+	if err == nil {
+		err = x.err
+	}
+	if err != nil {
+		x.close()
+		return nil, err
+	}
+	return x, nil
+}
+
+//put writes the entry of a target event, the file being only seeked when the entry does not follow the previous one
+func (p *sourcePartition) put(m offsetMapping) error {
+	position := m.targetOffset - p.first
+	if position != p.next {
+		if err := p.buf.Flush(); err != nil {
+			return err
+		}
+		if _, err := p.file.Seek(position*sourceEntrySize, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	p.next = position + 1
+
+	var entry [sourceEntrySize]byte
+	entry[0] = 1
+	binary.BigEndian.PutUint32(entry[1:], uint32(m.sourcePartition))
+	binary.BigEndian.PutUint64(entry[5:], uint64(m.sourceOffset))
+	_, err := p.buf.Write(entry[:])
+	return err
+}
+
+//source returns the source event of a target event, false if it is missing from the offset map
+func (x *sourceIndex) source(target eventPosition) (eventPosition, bool) {
+	p, ok := x.partitions[target.partition]
+	if !ok || x.err != nil || target.offset < p.first {
+		return eventPosition{}, false
+	}
+
+	position := target.offset - p.first
+	if !p.holds(position) {
+		if x.err = p.load(position); x.err != nil || !p.holds(position) {
+			return eventPosition{}, false
+		}
+	}
+	entry := p.window[(position-p.start)*sourceEntrySize:]
+	if entry[0] == 0 {
+		return eventPosition{}, false
+	}
+	return eventPosition{partition: int32(binary.BigEndian.Uint32(entry[1:])), offset: int64(binary.BigEndian.Uint64(entry[5:]))}, true
+}
+
+func (p *sourcePartition) holds(position int64) bool {
+	return position >= p.start && position < p.start+int64(len(p.window)/sourceEntrySize)
+}
+
+//load reads the window of entries starting at a position, shorter at the end of the file
+func (p *sourcePartition) load(position int64) error {
+	if p.window == nil {
+		p.window = make([]byte, sourceWindow*sourceEntrySize)
+	}
+	n, err := p.file.ReadAt(p.window[:cap(p.window)], position*sourceEntrySize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	p.window = p.window[:n-n%sourceEntrySize]
+	p.start = position
+	return nil
+}
+
+//close removes the index, nil being a no-op
+func (x *sourceIndex) close() error {
+	if x == nil {
+		return nil
+	}
+	for _, p := range x.partitions {
+		p.file.Close()
+	}
+	x.partitions = nil
+	return os.RemoveAll(x.dir)
+}
@@ -0,0 +1,256 @@
+//+build unit
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+)
+
+type verifyMessage struct {
+	side      int
+	partition int32
+	key       string
+	value     string
+}
+
+func addAll(c comparison, msgs []verifyMessage) {
+	for _, m := range msgs {
+		c.add(m.side, &sarama.ConsumerMessage{Partition: m.partition, Key: []byte(m.key), Value: []byte(m.value)})
+	}
+}
+
+func TestVerifyByKey(t *testing.T) {
+	//Arrange
+	c := verifyParameters{by: verifyByKey}.newComparison(nil)
+	msgs := []verifyMessage{
+		//Identical, on other partitions
+		{sourceSide, 0, "a", "1"}, {targetSide, 2, "a", "1"},
+		//Reordered
+		{sourceSide, 0, "b", "1"}, {sourceSide, 0, "b", "2"}, {targetSide, 1, "b", "2"}, {targetSide, 1, "b", "1"},
+		//Mismatched
+		{sourceSide, 1, "c", "1"}, {targetSide, 1, "c", "2"},
+		{sourceSide, 1, "d", "1"}, {targetSide, 1, "d", "1"}, {targetSide, 1, "d", "1"},
+		//Missing and extra
+		{sourceSide, 1, "e", "1"},
+		{targetSide, 1, "f", "1"},
+	}
+
+	//Act
+	addAll(c, msgs)
+
+	//Assert
+	assert.Equal(t, c.lines(), []string{
+		"consumed from source: 6",
+		"consumed from target: 7",
+		"matching keys: 1",
+		"keys missing from target: 1",
+		"extra keys in target: 1",
+		"keys with mismatched events: 2",
+		"keys with reordered events: 1",
+	})
+	assert.Equal(t, c.differs(), true)
+}
+
+func TestVerifyByPartition(t *testing.T) {
+	//Arrange
+	c := verifyParameters{by: verifyByPartition}.newComparison(nil)
+	msgs := []verifyMessage{
+		//Identical, the target being read first
+		{targetSide, 0, "a", "1"}, {targetSide, 0, "b", "1"}, {sourceSide, 0, "a", "1"}, {sourceSide, 0, "b", "1"},
+		//Reordered
+		{sourceSide, 1, "a", "1"}, {sourceSide, 1, "b", "1"}, {targetSide, 1, "b", "1"}, {targetSide, 1, "a", "1"},
+		//Mismatched and missing
+		{sourceSide, 2, "a", "1"}, {sourceSide, 2, "b", "1"}, {sourceSide, 2, "c", "1"}, {targetSide, 2, "a", "2"},
+	}
+
+	//Act
+	addAll(c, msgs)
+
+	//Assert
+	assert.Equal(t, c.lines(), []string{
+		"partition 0: identical, source: 2, target: 2, matching: 2, mismatched: 0, missing from target: 0, extra in target: 0, unchecked: 0",
+		"partition 1: reordered, source: 2, target: 2, matching: 0, mismatched: 2, missing from target: 0, extra in target: 0, unchecked: 0",
+		"partition 2: different, source: 3, target: 1, matching: 0, mismatched: 1, missing from target: 2, extra in target: 0, unchecked: 0",
+	})
+	assert.Equal(t, c.differs(), true)
+}
+
+func TestVerifyByPartitionOffsetMap(t *testing.T) {
+	//Arrange
+	//The source event 0/1 was not cloned, the source partitions 0 and 1 being merged into the target partition 0
+	sources, err := indexSources(strings.NewReader(offsetMapLines("0,0,foo,0,10", "0,2,foo,0,11", "1,0,foo,0,12", "0,3,foo,0,13")), "foo")
+	assert.Equal(t, err, nil)
+	defer sources.close()
+	c := verifyParameters{by: verifyByPartition}.newComparison(sources)
+	msgs := []struct {
+		side      int
+		partition int32
+		offset    int64
+		value     string
+	}{
+		{sourceSide, 0, 0, "a"}, {sourceSide, 0, 1, "b"}, {sourceSide, 0, 2, "c"}, {sourceSide, 0, 3, "d"}, {sourceSide, 1, 0, "e"},
+		{targetSide, 0, 10, "a"}, {targetSide, 0, 11, "c"}, {targetSide, 0, 12, "e"}, {targetSide, 0, 13, "x"}, {targetSide, 0, 14, "f"},
+	}
+
+	//Act
+	for _, m := range msgs {
+		c.add(m.side, &sarama.ConsumerMessage{Partition: m.partition, Offset: m.offset, Value: []byte(m.value)})
+	}
+
+	//Assert
+	assert.Equal(t, c.lines(), []string{
+		"partition 0: different, source: 4, target: 3, matching: 2, mismatched: 1, missing from target: 1, extra in target: 0, unchecked: 0",
+		"partition 1: identical, source: 1, target: 1, matching: 1, mismatched: 0, missing from target: 0, extra in target: 0, unchecked: 0",
+		"target events missing from the offset map: 1",
+	})
+	assert.Equal(t, c.differs(), true)
+}
+
+func TestVerifyByPartitionPendingCap(t *testing.T) {
+	tests := []struct {
+		name     string
+		changed  int
+		expected string
+	}{
+		{name: "identical", changed: -1, expected: "identical"},
+		{name: "changed among the unchecked events", changed: 0, expected: "different"},
+		{name: "changed among the pending events", changed: 3, expected: "different"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Arrange
+			c := verifyParameters{by: verifyByPartition}.newComparison(nil).(*partitionComparison)
+			n := verifyMaxPending + 2
+			for i := 0; i < n; i++ {
+				c.add(sourceSide, &sarama.ConsumerMessage{Value: []byte(fmt.Sprint(i))})
+			}
+
+			//Act
+			for i := 0; i < n; i++ {
+				value := fmt.Sprint(i)
+				if i == tt.changed {
+					value = "changed"
+				}
+				c.add(targetSide, &sarama.ConsumerMessage{Value: []byte(value)})
+			}
+
+			//Assert
+			d := c.partitions[0]
+			assert.Equal(t, len(d.pending[sourceSide]), 0)
+			assert.Equal(t, len(d.queued[sourceSide]) <= verifyMaxPending, true)
+			assert.Equal(t, d.unchecked, int64(2))
+			assert.Equal(t, d.status(), tt.expected)
+		})
+	}
+}
+
+func offsetMapLines(lines ...string) string {
+	return strings.Join(append([]string{strings.Join(offsetMapHeader, ",")}, lines...), "\n") + "\n"
+}
+
+func TestSourceIndex(t *testing.T) {
+	//Arrange
+	//The target offsets of partition 1 are out of order, with a gap, and beyond the window of entries read at once
+	far := int64(7 + 2*sourceWindow)
+	offsetMap := offsetMapLines("0,5,foo,1,9", "0,6,bar,1,8", "0,4,foo,1,7", "2,3,foo,1,"+fmt.Sprint(far), "0,9,foo,0,0")
+
+	//Act
+	sources, err := indexSources(strings.NewReader(offsetMap), "foo")
+
+	//Assert
+	assert.Equal(t, err, nil)
+	defer sources.close()
+	tests := []struct {
+		target   eventPosition
+		expected eventPosition
+		found    bool
+	}{
+		{target: eventPosition{1, 7}, expected: eventPosition{0, 4}, found: true},
+		{target: eventPosition{1, 9}, expected: eventPosition{0, 5}, found: true},
+		{target: eventPosition{1, far}, expected: eventPosition{2, 3}, found: true},
+		{target: eventPosition{1, 8}},
+		{target: eventPosition{1, 6}},
+		{target: eventPosition{1, far + 1}},
+		{target: eventPosition{0, 0}, expected: eventPosition{0, 9}, found: true},
+		{target: eventPosition{2, 0}},
+	}
+	for _, tt := range tests {
+		source, found := sources.source(tt.target)
+		assert.Equal(t, found, tt.found, fmt.Sprint(tt.target))
+		assert.Equal(t, source, tt.expected, fmt.Sprint(tt.target))
+	}
+	assert.Equal(t, sources.err, nil)
+}
+
+func TestOpenSourceIndex(t *testing.T) {
+	//Arrange
+	file, err := ioutil.TempFile("", "offsets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(offsetMapLines("0,5,foo,1,7"))
+	file.Close()
+
+	//Act
+	sources, err := openSourceIndex(file.Name(), "foo")
+	_, missingErr := openSourceIndex(file.Name()+"-missing", "foo")
+
+	//Assert
+	assert.Equal(t, err, nil)
+	source, found := sources.source(eventPosition{1, 7})
+	assert.Equal(t, found, true)
+	assert.Equal(t, source, eventPosition{0, 5})
+	dir := sources.dir
+	sources.close()
+	_, statErr := os.Stat(dir)
+	assert.Equal(t, os.IsNotExist(statErr), true)
+	assert.Equal(t, missingErr != nil, true)
+}
+
+func TestVerifyIdentical(t *testing.T) {
+	for _, by := range possibleVerify {
+		//Arrange
+		c := verifyParameters{by: by}.newComparison(nil)
+
+		//Act
+		addAll(c, []verifyMessage{{sourceSide, 0, "a", "1"}, {targetSide, 0, "a", "1"}})
+
+		//Assert
+		assert.Equal(t, c.differs(), false)
+	}
+}
+
+func TestValidateVerifyParameters(t *testing.T) {
+	tests := []struct {
+		params   parameters
+		by       string
+		expected error
+	}{
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "baz"}, verifyByKey, nil},
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "bar", toBrokers: "other"}, verifyByPartition, nil},
+		{parameters{fromBrokers: "foo", toTopic: "baz"}, verifyByKey, errMissingSourceTopic},
+		{parameters{fromBrokers: "foo", fromTopic: "bar"}, verifyByKey, errMissingTargetTopic},
+		{parameters{fromTopic: "bar", toTopic: "baz"}, verifyByKey, errMissingSourceBrokers},
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "bar"}, verifyByKey, errVerifySameTopic},
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "baz"}, "offset", errUnknownVerifyMode},
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "baz", offsetMap: "foo.csv"}, verifyByPartition, nil},
+		{parameters{fromBrokers: "foo", fromTopic: "bar", toTopic: "baz", offsetMap: "foo.csv"}, verifyByKey, errVerifyOffsetMapByKey},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := verifyParameters{by: tt.by}.validate(tt.params)
+
+		//Assert
+		assert.Equal(t, actual, tt.expected)
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+//workers process the consumed messages in parallel. The messages of a source partition are always processed by the same worker,
+//so the messages sharing a key are produced in the order they were consumed.
+type workers struct {
+	inputs []chan []*sarama.ConsumerMessage
+	stats  []*summary
+	wg     sync.WaitGroup
+}
+
+//startWorkers starts n workers running their own copy of the pipeline, with their own summary. send must be safe for concurrent use.
+//The consumed messages are tracked by acked until the messages produced for them are acknowledged, if it is not nil, and the
+//produced messages carry their consumed message in their metadata when acked is not nil or the pipeline records the sources.
+func startWorkers(n int, pipe *pipeline, acked *acks, send func(*sarama.ProducerMessage)) *workers {
+	w := &workers{}
+	for i := 0; i < n; i++ {
+		input := make(chan []*sarama.ConsumerMessage, 16)
+		local := *pipe
+		local.stats = newSummary()
+
+		emit := func(msgP *sarama.ProducerMessage) {
+			send(msgP)
+			local.stats.produced++
+			if local.verbose {
+				log.Print("message produced")
+			}
+		}
+
+		w.inputs = append(w.inputs, input)
+		w.stats = append(w.stats, local.stats)
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for batch := range input {
+				for _, msgC := range batch {
+					if acked == nil && !local.sources {
+						local.processTo(msgC, emit)
+						continue
+					}
+					var ack *sourceAck
+					if acked != nil {
+						ack = acked.begin(msgC)
+					}
+					local.processTo(msgC, func(msgP *sarama.ProducerMessage) {
+						attach(msgP, msgC, ack)
+						emit(msgP)
+					})
+					if ack != nil {
+						ack.done()
+					}
+				}
+				putBatch(batch)
+			}
+		}()
+	}
+	return w
+}
+
+//dispatch hands the message to the worker of its partition
+func (w *workers) dispatch(msgC *sarama.ConsumerMessage) {
+	batch := getBatch(1)
+	w.dispatchBatch(append(batch, msgC))
+}
+
+//dispatchBatch hands the messages to the workers of their partitions, with a single hand-over per worker
+func (w *workers) dispatchBatch(batch []*sarama.ConsumerMessage) {
+	if len(w.inputs) == 1 {
+		w.inputs[0] <- batch
+		return
+	}
+
+	split := make([][]*sarama.ConsumerMessage, len(w.inputs))
+	for _, msgC := range batch {
+		i := w.worker(msgC.Partition)
+		if split[i] == nil {
+			split[i] = getBatch(len(batch))
+		}
+		split[i] = append(split[i], msgC)
+	}
+	putBatch(batch)
+
+	for i, b := range split {
+		if b != nil {
+			w.inputs[i] <- b
+		}
+	}
+}
+
+func (w *workers) worker(partition int32) int {
+	return int(partition) % len(w.inputs)
+}
+
+//stop waits for the dispatched messages to be processed, and adds the summaries of the workers to stats
+func (w *workers) stop(stats *summary) {
+	for _, input := range w.inputs {
+		close(input)
+	}
+	w.wg.Wait()
+	for _, s := range w.stats {
+		stats.add(s)
+	}
+}
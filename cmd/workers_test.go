@@ -0,0 +1,95 @@
+//+build unit
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/magiconair/properties/assert"
+	"github.com/ricardo-ch/kafka-topic-cloner/kafka"
+)
+
+func TestWorkers(t *testing.T) {
+	//Arrange
+	stats := newSummary()
+	pipe := &pipeline{topic: "bar", filters: kafka.Filters{kafka.NewKeyPrefixFilter("keep")}, transformer: kafka.Chain{}, stats: stats}
+
+	var mu sync.Mutex
+	produced := make(map[string][]string)
+	send := func(msg *sarama.ProducerMessage) {
+		key, _ := msg.Key.Encode()
+		value, _ := msg.Value.Encode()
+		mu.Lock()
+		produced[string(key)] = append(produced[string(key)], string(value))
+		mu.Unlock()
+	}
+
+	//Act
+	w := startWorkers(3, pipe, nil, send)
+	for i := 0; i < 100; i++ {
+		partition := int32(i % 5)
+		w.dispatch(&sarama.ConsumerMessage{
+			Partition: partition,
+			Key:       []byte(fmt.Sprintf("keep-%d", partition)),
+			Value:     []byte(fmt.Sprint(i)),
+		})
+		w.dispatch(&sarama.ConsumerMessage{Partition: partition, Key: []byte("drop")})
+	}
+	w.stop(stats)
+
+	//Assert
+	assert.Equal(t, stats.consumed, 200)
+	assert.Equal(t, stats.produced, 100)
+	assert.Equal(t, stats.filtered["key-prefix(keep)"], 100)
+	for p := 0; p < 5; p++ {
+		var expected []string
+		for i := p; i < 100; i += 5 {
+			expected = append(expected, fmt.Sprint(i))
+		}
+		assert.Equal(t, produced[fmt.Sprintf("keep-%d", p)], expected)
+	}
+}
+
+func BenchmarkWorkers(b *testing.B) {
+	//Redacting JSON values is representative of the processing cost per message
+	redactor, _ := kafka.NewRedactor("secret", []string{"$.customer.email:fake-email", "$.customer.name:fake-name", "$.items[*].price:null"}, "")
+	value := []byte(`{"id":42,"customer":{"email":"foo@bar.com","name":"Foo Bar"},"items":[{"id":1,"price":10},{"id":2,"price":20}]}`)
+
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d workers", n), func(b *testing.B) {
+			pipe := &pipeline{topic: "bar", transformer: kafka.Chain{}, redactor: redactor, stats: newSummary()}
+			w := startWorkers(n, pipe, nil, func(*sarama.ProducerMessage) {})
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				w.dispatch(&sarama.ConsumerMessage{Partition: int32(i % 8), Key: []byte("foo"), Value: value})
+			}
+			w.stop(newSummary())
+		})
+	}
+}
+
+//BenchmarkCloneHotPath measures the clone loop without the brokers: consuming small messages, processing and sending them
+func BenchmarkCloneHotPath(b *testing.B) {
+	for _, batch := range []int{1, 100} {
+		b.Run(fmt.Sprintf("batches of %d", batch), func(b *testing.B) {
+			pipe, _ := parameters{toTopic: "bar"}.buildPipeline(newSummary())
+			messages := make(chan *sarama.ConsumerMessage, 1024)
+			go func() {
+				for i := 0; i < b.N; i++ {
+					messages <- &sarama.ConsumerMessage{Partition: int32(i % 8), Key: []byte("foo"), Value: []byte(`{"id":42}`)}
+				}
+				close(messages)
+			}()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			w := startWorkers(2, pipe, nil, func(*sarama.ProducerMessage) {})
+			consumeBatches(messages, batch, nil, w.dispatchBatch)
+			w.stop(newSummary())
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+//Headers added to the chunks of a split message. The chunks of a message share its key, headers and partition, and are
+//produced in order: a consumer joins the values of the chunks sharing an ID once it has read chunk-count of them.
+const (
+	ChunkIDHeader    = "chunk-id"
+	ChunkIndexHeader = "chunk-index"
+	ChunkCountHeader = "chunk-count"
+	ChunkSizeHeader  = "chunk-size"
+)
+
+//chunkHeadersSize bounds the size of the chunk headers: a 32 characters ID, and indexes, counts and sizes of up to 20 digits
+var chunkHeadersSize = len(ChunkIDHeader) + 32 + len(ChunkIndexHeader) + 20 + len(ChunkCountHeader) + 20 + len(ChunkSizeHeader) + 20 + 4*maxHeaderOverhead
+
+//ErrRecordTooLarge is returned when a message cannot be split, its key and headers alone exceeding the maximum record size
+var ErrRecordTooLarge = errors.New("record too large to be split, its key and headers exceed the maximum record size")
+
+//Split splits the value of a message into chunks no larger than maxRecordSize once encoded, with the headers needed to reassemble them.
+//ChunkSizeHeader holds the size of the whole value. The chunks keep the metadata and the partition of the message: they must
+//be produced with manual partitioning, once the partition of the message is computed, to land on the same partition.
+func Split(msg *sarama.ProducerMessage, maxRecordSize int) ([]*sarama.ProducerMessage, error) {
+	var value []byte
+	if msg.Value != nil {
+		var err error
+		if value, err = msg.Value.Encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	fixed := RecordSize(&sarama.ProducerMessage{Key: msg.Key, Headers: msg.Headers}) + chunkHeadersSize
+	chunkSize := maxRecordSize - fixed
+	if chunkSize <= 0 {
+		return nil, ErrRecordTooLarge
+	}
+
+	id, err := chunkID()
+	if err != nil {
+		return nil, err
+	}
+	count := (len(value) + chunkSize - 1) / chunkSize
+	if count == 0 {
+		count = 1
+	}
+
+	chunks := make([]*sarama.ProducerMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+4)
+		headers = append(headers, msg.Headers...)
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte(ChunkIDHeader), Value: []byte(id)},
+			sarama.RecordHeader{Key: []byte(ChunkIndexHeader), Value: []byte(strconv.Itoa(i))},
+			sarama.RecordHeader{Key: []byte(ChunkCountHeader), Value: []byte(strconv.Itoa(count))},
+			sarama.RecordHeader{Key: []byte(ChunkSizeHeader), Value: []byte(strconv.Itoa(len(value)))},
+		)
+		chunks = append(chunks, &sarama.ProducerMessage{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Value:     sarama.ByteEncoder(value[i*chunkSize : end]),
+			Headers:   headers,
+			Partition: msg.Partition,
+			Timestamp: msg.Timestamp,
+			Metadata:  msg.Metadata,
+		})
+	}
+	return chunks, nil
+}
+
+func chunkID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
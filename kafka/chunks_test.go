@@ -0,0 +1,70 @@
+//+build unit
+
+package kafka
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func chunkHeaders(msg *sarama.ProducerMessage) map[string]string {
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return headers
+}
+
+func TestSplit(t *testing.T) {
+	//Arrange
+	value := []byte(strings.Repeat("0123456789", 100))
+	msg := &sarama.ProducerMessage{
+		Topic:     "foo",
+		Partition: 2,
+		Key:       sarama.StringEncoder("bar"),
+		Value:     sarama.ByteEncoder(value),
+		Headers:   []sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("42")}},
+	}
+	maxRecordSize := RecordSize(&sarama.ProducerMessage{Key: msg.Key, Headers: msg.Headers}) + chunkHeadersSize + 300
+
+	//Act
+	chunks, err := Split(msg, maxRecordSize)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 4)
+	var joined []byte
+	for i, chunk := range chunks {
+		assert.True(t, RecordSize(chunk) <= maxRecordSize)
+		assert.Equal(t, "foo", chunk.Topic)
+		assert.Equal(t, int32(2), chunk.Partition)
+		assert.Equal(t, msg.Key, chunk.Key)
+
+		headers := chunkHeaders(chunk)
+		assert.Equal(t, "42", headers["trace"])
+		assert.Equal(t, chunkHeaders(chunks[0])[ChunkIDHeader], headers[ChunkIDHeader])
+		assert.Equal(t, string(rune('0'+i)), headers[ChunkIndexHeader])
+		assert.Equal(t, "4", headers[ChunkCountHeader])
+		assert.Equal(t, "1000", headers[ChunkSizeHeader])
+
+		b, _ := chunk.Value.Encode()
+		joined = append(joined, b...)
+	}
+	assert.True(t, bytes.Equal(value, joined))
+	assert.Len(t, msg.Headers, 1)
+}
+
+func TestSplitTooLarge(t *testing.T) {
+	//Arrange
+	msg := &sarama.ProducerMessage{Key: sarama.StringEncoder(strings.Repeat("k", 100)), Value: sarama.StringEncoder("foo")}
+
+	//Act
+	_, err := Split(msg, 100)
+
+	//Assert
+	assert.Equal(t, ErrRecordTooLarge, err)
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// ErrFieldNotFound is returned when a message lacks the field it is partitioned on
+var ErrFieldNotFound = errors.New("partitioning field not found")
+
+// Field extracts the field a message is partitioned on, instead of its key
+type Field func(msg *sarama.ProducerMessage) ([]byte, error)
+
+// NewJSONField extracts a field of the JSON values, such as "$.customerId".
+// Scalars are hashed as they are written, a string without its quotes.
+func NewJSONField(path string) (Field, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(msg *sarama.ProducerMessage) ([]byte, error) {
+		value, err := encoded(msg.Value)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, ErrFieldNotFound
+		}
+		doc, err := decodeJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		field, found := lookupJSONPath(doc, steps)
+		if !found {
+			return nil, fmt.Errorf("%v: %s", ErrFieldNotFound, path)
+		}
+		return []byte(jsonValueString(field)), nil
+	}, nil
+}
+
+// NewHeaderField extracts the value of a header, the first one if the header is repeated
+func NewHeaderField(name string) Field {
+	key := []byte(name)
+	return func(msg *sarama.ProducerMessage) ([]byte, error) {
+		for _, h := range msg.Headers {
+			if bytes.Equal(h.Key, key) {
+				return h.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("%v: header %s", ErrFieldNotFound, name)
+	}
+}
+
+// NewFieldPartitioner returns a partitioner placing a message where the partitioner of the hasher places a message whose
+// key is the field. It returns an error for the messages lacking the field.
+func NewFieldPartitioner(hasher string, field Field) sarama.PartitionerConstructor {
+	return func(topic string) sarama.Partitioner {
+		return &fieldPartitioner{field: field, partitioner: NewPartitioner(hasher, topic)}
+	}
+}
+
+type fieldPartitioner struct {
+	field       Field
+	partitioner sarama.Partitioner
+}
+
+func (p *fieldPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	value, err := p.field(msg)
+	if err != nil {
+		return -1, err
+	}
+	return p.partitioner.Partition(&sarama.ProducerMessage{Topic: msg.Topic, Key: sarama.ByteEncoder(value)}, numPartitions)
+}
+
+func (p *fieldPartitioner) RequiresConsistency() bool {
+	return p.partitioner.RequiresConsistency()
+}
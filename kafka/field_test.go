@@ -0,0 +1,106 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJSONField(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		err      bool
+	}{
+		{value: `{"customerId":"foo"}`, expected: "foo"},
+		{value: `{"customerId":42}`, expected: "42"},
+		{value: `{"customer":{"id":"bar"}}`, err: true},
+		{value: `not json`, err: true},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		field, err := NewJSONField("$.customerId")
+		assert.NoError(t, err)
+
+		//Act
+		actual, err := field(&sarama.ProducerMessage{Value: sarama.StringEncoder(tt.value)})
+
+		//Assert
+		if tt.err {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.expected, string(actual))
+	}
+}
+
+func TestNewJSONFieldInvalidPath(t *testing.T) {
+	_, err := NewJSONField("$.")
+	assert.Error(t, err)
+}
+
+func TestNewHeaderField(t *testing.T) {
+	//Arrange
+	field := NewHeaderField("customer")
+	msg := &sarama.ProducerMessage{Headers: []sarama.RecordHeader{
+		{Key: []byte("trace"), Value: []byte("1")},
+		{Key: []byte("customer"), Value: []byte("foo")},
+		{Key: []byte("customer"), Value: []byte("bar")},
+	}}
+
+	//Act
+	actual, err := field(msg)
+	_, missingErr := field(&sarama.ProducerMessage{})
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(actual))
+	assert.Error(t, missingErr)
+}
+
+func TestFieldPartitioner(t *testing.T) {
+	//The messages are placed where the hasher places their field as key: with 3 partitions, murmur2 places
+	//foo on 2, foobar on 0 and 42 on 1, FNV-1a places them on 1, 0 and 2
+	tests := []struct {
+		hasher   string
+		expected []int32
+	}{
+		{hasher: "murmur2", expected: []int32{2, 0, 1}},
+		{hasher: "FNV-1a", expected: []int32{1, 0, 2}},
+		{hasher: "crc32", expected: []int32{2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		field, _ := NewJSONField("$.customerId")
+		p := NewFieldPartitioner(tt.hasher, field)("foo")
+		var actual []int32
+
+		//Act
+		for _, value := range []string{`{"customerId":"foo"}`, `{"customerId":"foobar"}`, `{"customerId":42}`} {
+			partition, err := p.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("unrelated"), Value: sarama.StringEncoder(value)}, 3)
+			assert.NoError(t, err)
+			actual = append(actual, partition)
+		}
+
+		//Assert
+		assert.Equal(t, tt.expected, actual, tt.hasher)
+		assert.True(t, p.RequiresConsistency())
+	}
+}
+
+func TestFieldPartitionerMissingField(t *testing.T) {
+	//Arrange
+	p := NewFieldPartitioner("murmur2", NewHeaderField("customer"))("foo")
+
+	//Act
+	_, err := p.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("foo")}, 3)
+
+	//Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	errInvalidHeaderFilter = errors.New("invalid header filter, expected name or name=value")
+	errInvalidJSONFilter   = errors.New("invalid JSON filter, expected path, path=value or path!=value")
+)
+
+// Filter decides whether a consumed message has to be cloned or not.
+// String is used to identify the filter in the run summary.
+type Filter interface {
+	Match(msg *sarama.ConsumerMessage) bool
+	String() string
+}
+
+// Filters is a chain of filters, a message has to match every one of them to be cloned
+type Filters []Filter
+
+// Reject returns the first filter that the message does not match, or nil if the message has to be cloned
+func (f Filters) Reject(msg *sarama.ConsumerMessage) Filter {
+	for _, filter := range f {
+		if !filter.Match(msg) {
+			return filter
+		}
+	}
+	return nil
+}
+
+type keyEqualsFilter struct {
+	key []byte
+}
+
+// NewKeyEqualsFilter keeps the messages whose key is exactly the given one
+func NewKeyEqualsFilter(key string) Filter {
+	return keyEqualsFilter{key: []byte(key)}
+}
+
+func (f keyEqualsFilter) Match(msg *sarama.ConsumerMessage) bool {
+	return msg.Key != nil && bytes.Equal(msg.Key, f.key)
+}
+
+func (f keyEqualsFilter) String() string {
+	return fmt.Sprintf("key-equals(%s)", f.key)
+}
+
+type keyPrefixFilter struct {
+	prefix []byte
+}
+
+// NewKeyPrefixFilter keeps the messages whose key starts with the given prefix
+func NewKeyPrefixFilter(prefix string) Filter {
+	return keyPrefixFilter{prefix: []byte(prefix)}
+}
+
+func (f keyPrefixFilter) Match(msg *sarama.ConsumerMessage) bool {
+	return msg.Key != nil && bytes.HasPrefix(msg.Key, f.prefix)
+}
+
+func (f keyPrefixFilter) String() string {
+	return fmt.Sprintf("key-prefix(%s)", f.prefix)
+}
+
+type keyRegexFilter struct {
+	re *regexp.Regexp
+}
+
+// NewKeyRegexFilter keeps the messages whose key matches the given regular expression
+func NewKeyRegexFilter(expr string) (Filter, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return keyRegexFilter{re: re}, nil
+}
+
+func (f keyRegexFilter) Match(msg *sarama.ConsumerMessage) bool {
+	return msg.Key != nil && f.re.Match(msg.Key)
+}
+
+func (f keyRegexFilter) String() string {
+	return fmt.Sprintf("key-regex(%s)", f.re)
+}
+
+type headerFilter struct {
+	name     []byte
+	value    []byte
+	anyValue bool
+}
+
+// NewHeaderFilter keeps the messages carrying the given header.
+// The expression is either "name", matching any value, or "name=value".
+func NewHeaderFilter(expr string) (Filter, error) {
+	parts := strings.SplitN(expr, "=", 2)
+	if parts[0] == "" {
+		return nil, errInvalidHeaderFilter
+	}
+
+	f := headerFilter{name: []byte(parts[0]), anyValue: len(parts) == 1}
+	if !f.anyValue {
+		f.value = []byte(parts[1])
+	}
+	return f, nil
+}
+
+func (f headerFilter) Match(msg *sarama.ConsumerMessage) bool {
+	for _, h := range msg.Headers {
+		if h != nil && bytes.Equal(h.Key, f.name) && (f.anyValue || bytes.Equal(h.Value, f.value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f headerFilter) String() string {
+	if f.anyValue {
+		return fmt.Sprintf("header(%s)", f.name)
+	}
+	return fmt.Sprintf("header(%s=%s)", f.name, f.value)
+}
+
+type jsonFilter struct {
+	expr     string
+	path     []jsonPathStep
+	value    string
+	anyValue bool
+	negate   bool
+}
+
+// NewJSONFilter keeps the messages whose JSON value satisfies the given predicate.
+// The expression is either "path", matching when the field exists, "path=value" or "path!=value".
+// Messages whose value is not valid JSON never match.
+func NewJSONFilter(expr string) (Filter, error) {
+	f := jsonFilter{expr: expr, anyValue: true}
+
+	path := expr
+	if i := strings.Index(expr, "!="); i >= 0 {
+		path, f.value, f.anyValue, f.negate = expr[:i], expr[i+2:], false, true
+	} else if i := strings.Index(expr, "="); i >= 0 {
+		path, f.value, f.anyValue = expr[:i], expr[i+1:], false
+	}
+	if path == "" {
+		return nil, errInvalidJSONFilter
+	}
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f.path = steps
+	return f, nil
+}
+
+func (f jsonFilter) Match(msg *sarama.ConsumerMessage) bool {
+	if msg.Value == nil {
+		return false
+	}
+	doc, err := decodeJSON(msg.Value)
+	if err != nil {
+		return false
+	}
+
+	v, found := lookupJSONPath(doc, f.path)
+	switch {
+	case f.anyValue:
+		return found
+	case f.negate:
+		return !found || jsonValueString(v) != f.value
+	default:
+		return found && jsonValueString(v) == f.value
+	}
+}
+
+func (f jsonFilter) String() string {
+	return fmt.Sprintf("json(%s)", f.expr)
+}
@@ -0,0 +1,147 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type filterTest struct {
+	filter   Filter
+	msg      *sarama.ConsumerMessage
+	expected bool
+}
+
+func mustFilter(f Filter, err error) Filter {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+var filterTestCases = []filterTest{
+	{
+		filter:   NewKeyEqualsFilter("foo"),
+		msg:      &sarama.ConsumerMessage{Key: []byte("foo")},
+		expected: true,
+	},
+	{
+		filter:   NewKeyEqualsFilter("foo"),
+		msg:      &sarama.ConsumerMessage{Key: []byte("foobar")},
+		expected: false,
+	},
+	{
+		filter:   NewKeyEqualsFilter(""),
+		msg:      &sarama.ConsumerMessage{},
+		expected: false,
+	},
+	{
+		filter:   NewKeyPrefixFilter("customer-42"),
+		msg:      &sarama.ConsumerMessage{Key: []byte("customer-42/order-1")},
+		expected: true,
+	},
+	{
+		filter:   NewKeyPrefixFilter("customer-42"),
+		msg:      &sarama.ConsumerMessage{Key: []byte("customer-43/order-1")},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewKeyRegexFilter("^order-[0-9]+$")),
+		msg:      &sarama.ConsumerMessage{Key: []byte("order-42")},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewKeyRegexFilter("^order-[0-9]+$")),
+		msg:      &sarama.ConsumerMessage{Key: []byte("order-foo")},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewHeaderFilter("type=created")),
+		msg:      &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}}},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewHeaderFilter("type=created")),
+		msg:      &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{{Key: []byte("type"), Value: []byte("deleted")}}},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewHeaderFilter("type")),
+		msg:      &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{{Key: []byte("type"), Value: []byte("deleted")}}},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewHeaderFilter("type")),
+		msg:      &sarama.ConsumerMessage{},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("$.customer.id=42")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`{"customer":{"id":42}}`)},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("customer.id=42")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`{"customer":{"id":43}}`)},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("type!=deleted")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`{"type":"created"}`)},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("items[1].sku")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`{"items":[{"sku":"a"},{"sku":"b"}]}`)},
+		expected: true,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("items[2].sku")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`{"items":[{"sku":"a"},{"sku":"b"}]}`)},
+		expected: false,
+	},
+	{
+		filter:   mustFilter(NewJSONFilter("type=created")),
+		msg:      &sarama.ConsumerMessage{Value: []byte(`not json`)},
+		expected: false,
+	},
+}
+
+func TestFilterMatch(t *testing.T) {
+	for _, v := range filterTestCases {
+		//Act
+		actual := v.filter.Match(v.msg)
+
+		//Assert
+		assert.Equal(t, v.expected, actual, v.filter.String())
+	}
+}
+
+func TestFiltersReject(t *testing.T) {
+	//Arrange
+	prefix := NewKeyPrefixFilter("foo")
+	equals := NewKeyEqualsFilter("foobar")
+	filters := Filters{prefix, equals}
+
+	//Act & Assert
+	assert.Nil(t, filters.Reject(&sarama.ConsumerMessage{Key: []byte("foobar")}))
+	assert.Equal(t, equals, filters.Reject(&sarama.ConsumerMessage{Key: []byte("foobaz")}))
+	assert.Equal(t, prefix, filters.Reject(&sarama.ConsumerMessage{Key: []byte("bar")}))
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	//Act
+	_, regexErr := NewKeyRegexFilter("(")
+	_, headerErr := NewHeaderFilter("=foo")
+	_, jsonErr := NewJSONFilter("=foo")
+	_, pathErr := NewJSONFilter("items[x]=foo")
+
+	//Assert
+	assert.Error(t, regexErr)
+	assert.Equal(t, errInvalidHeaderFilter, headerErr)
+	assert.Equal(t, errInvalidJSONFilter, jsonErr)
+	assert.Error(t, pathErr)
+}
@@ -12,43 +12,43 @@ func NewJVMCompatiblePartitioner(topic string) sarama.Partitioner {
 	return sarama.NewCustomHashPartitioner(MurmurHasher)(topic)
 }
 
-// murmurHash implements hash.Hash32 interface,
-// solely to conform to required hasher for Sarama.
-// it does not support streaming since it is not required for Sarama.
+// murmurHash implements the hash.Hash32 interface with the murmur2 algorithm of the JVM clients for Kafka.
+// murmur2 mixes the length of the data in its seed, so the written data is buffered, and hashed by Sum32 and Sum.
 type murmurHash struct {
-	v int32
+	buf []byte
 }
 
-// MurmurHasher creates murmur2 hasher implementing hash.Hash32 interface.
-// The implementation is not full and does not support streaming.
-// It only implements the interface to comply with sarama.NewCustomHashPartitioner signature.
-// But Sarama only uses Write method once, when writing keys and values of the message,
-// so streaming support is not necessary.
+// MurmurHasher creates a murmur2 hasher implementing the hash.Hash32 interface, as required by sarama.NewCustomHashPartitioner.
+// Sum32 returns the hash made positive the way the JVM clients do before taking the modulo of the number of partitions,
+// so that the sarama partitioner places the keys where the JVM clients do.
 func MurmurHasher() hash.Hash32 {
 	return new(murmurHash)
 }
 
+// Write adds data to the hash, it never returns an error
 func (m *murmurHash) Write(d []byte) (n int, err error) {
-	n = len(d)
-	m.v = murmur2(d)
-	return
+	m.buf = append(m.buf, d...)
+	return len(d), nil
 }
 
+// Reset empties the hash, keeping its buffer to avoid allocations
 func (m *murmurHash) Reset() {
-	m.v = 0
+	m.buf = m.buf[:0]
 }
 
-func (m *murmurHash) Size() int { return 32 }
+// Size returns the number of bytes Sum appends
+func (m *murmurHash) Size() int { return 4 }
 
 func (m *murmurHash) BlockSize() int { return 4 }
 
-// Sum is noop.
+// Sum appends the big-endian value of Sum32 to in
 func (m *murmurHash) Sum(in []byte) []byte {
-	return in
+	v := m.Sum32()
+	return append(in, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
 }
 
 func (m *murmurHash) Sum32() uint32 {
-	return uint32(toPositive(m.v))
+	return uint32(toPositive(murmur2(m.buf)))
 }
 
 // murmur2 implements hashing algorithm used by JVM clients for Kafka.
@@ -0,0 +1,89 @@
+//+build unit,go1.18
+
+package kafka
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+//referenceMurmur2 is a plain port of the Java client's Utils.murmur2, with unsigned arithmetic, to check murmur2 against
+func referenceMurmur2(data []byte) int32 {
+	const seed uint32 = 0x9747b28c
+	const m uint32 = 0x5bd1e995
+	const r = 24
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func FuzzMurmurHasher(f *testing.F) {
+	for _, v := range murmur2TestCases {
+		f.Add([]byte(v.value), uint8(1))
+	}
+	f.Add([]byte{}, uint8(0))
+	f.Add([]byte{0xff, 0x80, 0x00, 0x7f, 0xfe}, uint8(3))
+
+	f.Fuzz(func(t *testing.T, data []byte, split uint8) {
+		expected := uint32(toPositive(referenceMurmur2(data)))
+
+		//A single write
+		h := MurmurHasher()
+		h.Write(data)
+		if actual := h.Sum32(); actual != expected {
+			t.Fatalf("Sum32(%x) = %d, expected %d", data, actual, expected)
+		}
+		sum := h.Sum(nil)
+		if actual := binary.BigEndian.Uint32(sum); len(sum) != h.Size() || actual != expected {
+			t.Fatalf("Sum(%x) = %x, expected %d", data, sum, expected)
+		}
+
+		//Writes of split bytes, after a reset
+		h.Reset()
+		step := int(split) + 1
+		for i := 0; i < len(data); i += step {
+			end := i + step
+			if end > len(data) {
+				end = len(data)
+			}
+			h.Write(data[i:end])
+		}
+		if actual := h.Sum32(); actual != expected {
+			t.Fatalf("streamed Sum32(%x) by %d = %d, expected %d", data, step, actual, expected)
+		}
+	})
+}
+
+func TestReferenceMurmur2(t *testing.T) {
+	for _, v := range murmur2TestCases {
+		if actual := referenceMurmur2([]byte(v.value)); actual != v.expected {
+			t.Errorf("referenceMurmur2(%s) = %d, expected %d", v.value, actual, v.expected)
+		}
+	}
+}
@@ -15,23 +15,35 @@ func TestMurmurHasher(t *testing.T) {
 }
 
 type writeTest struct {
-	value        string
+	values       []string
 	expectedLen  int
-	expectedHash int32
+	expectedHash uint32
 	expectedErr  error
 }
 
 var writeTestCases = []writeTest{
 	{
-		value:        "foo",
+		values:       []string{"foo"},
 		expectedLen:  3,
 		expectedHash: 597841616,
 		expectedErr:  nil,
 	},
 	{
-		value:        "foobar",
+		values:       []string{"foobar"},
 		expectedLen:  6,
-		expectedHash: -790332482,
+		expectedHash: uint32(toPositive(-790332482)),
+		expectedErr:  nil,
+	},
+	{
+		values:       []string{"foo", "bar"},
+		expectedLen:  6,
+		expectedHash: uint32(toPositive(-790332482)),
+		expectedErr:  nil,
+	},
+	{
+		values:       []string{"f", "", "oob", "ar"},
+		expectedLen:  6,
+		expectedHash: uint32(toPositive(-790332482)),
 		expectedErr:  nil,
 	},
 }
@@ -40,13 +52,21 @@ func TestWrite(t *testing.T) {
 	for _, v := range writeTestCases {
 		//Arrange
 		var m murmurHash
+		actualLen := 0
+		var actualErr error
 
 		//Act
-		actualLen, actualErr := m.Write([]byte(v.value))
+		for _, value := range v.values {
+			n, err := m.Write([]byte(value))
+			actualLen += n
+			if err != nil {
+				actualErr = err
+			}
+		}
 
 		//Assert
 		assert.Equal(t, actualLen, v.expectedLen)
-		assert.Equal(t, m.v, v.expectedHash)
+		assert.Equal(t, m.Sum32(), v.expectedHash)
 		assert.Equal(t, actualErr, v.expectedErr)
 	}
 }
@@ -54,27 +74,29 @@ func TestWrite(t *testing.T) {
 func TestReset(t *testing.T) {
 	//Arrange
 	var m murmurHash
-	m.v = 42
-	var expected int32
-	expected = 0
+	m.Write([]byte("foo"))
+	expected := uint32(toPositive(murmur2(nil)))
 
 	//Act
 	m.Reset()
 
 	//Assert
-	assert.Equal(t, m.v, expected)
+	assert.Equal(t, m.Sum32(), expected)
+	m.Write([]byte("foo"))
+	assert.Equal(t, m.Sum32(), uint32(597841616))
 }
 
 func TestSize(t *testing.T) {
 	//Arrange
 	var m murmurHash
-	expected := 32
+	expected := 4
 
 	//Act
 	actual := m.Size()
 
 	//Assert
 	assert.Equal(t, actual, expected)
+	assert.Equal(t, len(m.Sum(nil)), expected)
 }
 
 func TestBlockSize(t *testing.T) {
@@ -92,29 +114,32 @@ func TestBlockSize(t *testing.T) {
 func TestSum(t *testing.T) {
 	//Arrange
 	var m murmurHash
-	b := []byte("foo")
-	expected := b
+	m.Write([]byte("foo"))
+	b := []byte("prefix")
+	//597841616 is 0x23a256d0
+	expected := append([]byte("prefix"), 0x23, 0xa2, 0x56, 0xd0)
 
 	//Act
 	actual := m.Sum(b)
 
 	//Assert
 	assert.Equal(t, expected, actual)
+	assert.Equal(t, m.Sum32(), uint32(597841616))
 }
 
 type sum32Test struct {
-	value    int32
+	value    string
 	expected uint32
 }
 
 var sum32TestCases = []sum32Test{
 	{
-		value:    42,
-		expected: 42,
+		value:    "42",
+		expected: 417700972,
 	},
 	{
-		value:    -42,
-		expected: 2147483606,
+		value:    "21",
+		expected: uint32(toPositive(-973932308)),
 	},
 }
 
@@ -122,7 +147,7 @@ func TestSum32(t *testing.T) {
 	for _, v := range sum32TestCases {
 		//Arrange
 		var m murmurHash
-		m.v = v.value
+		m.Write([]byte(v.value))
 
 		//Act
 		actual := m.Sum32()
@@ -137,7 +162,28 @@ type murmur2Test struct {
 	expected int32
 }
 
+//The vectors of the Java client's UtilsTest.testMurmur2 are included
 var murmur2TestCases = []murmur2Test{
+	{
+		value:    "21",
+		expected: -973932308,
+	},
+	{
+		value:    "a-little-bit-long-string",
+		expected: -985981536,
+	},
+	{
+		value:    "a-little-bit-longer-string",
+		expected: -1486304829,
+	},
+	{
+		value:    "lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8",
+		expected: -58897971,
+	},
+	{
+		value:    "abc",
+		expected: 479470107,
+	},
 	{
 		value:    "foo",
 		expected: 597841616,
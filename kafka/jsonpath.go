@@ -0,0 +1,302 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errInvalidJSONPath = errors.New("invalid JSON path")
+
+const (
+	// jsonPathField is the index of the steps selecting an object field
+	jsonPathField = -1
+	// jsonPathWildcard is the index of a "[*]" step, matching every element of an array.
+	// It is only supported by replaceJSONPath.
+	jsonPathWildcard = -2
+)
+
+// jsonPathStep is a single segment of a JSON path, either an object field or an array index.
+type jsonPathStep struct {
+	field string
+	index int
+}
+
+// parseJSONPath parses a dot-separated path such as "$.customer.addresses[0].city".
+// The leading "$." is optional.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil, errInvalidJSONPath
+	}
+
+	var steps []jsonPathStep
+	for _, segment := range strings.Split(path, ".") {
+		field := segment
+		var indexes []int
+		if i := strings.Index(segment, "["); i >= 0 {
+			field = segment[:i]
+			for _, idx := range strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][") {
+				if idx == "*" {
+					indexes = append(indexes, jsonPathWildcard)
+					continue
+				}
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%v: %s", errInvalidJSONPath, path)
+				}
+				indexes = append(indexes, n)
+			}
+		}
+		if field == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("%v: %s", errInvalidJSONPath, path)
+		}
+		if field != "" {
+			steps = append(steps, jsonPathStep{field: field, index: jsonPathField})
+		}
+		for _, n := range indexes {
+			steps = append(steps, jsonPathStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath walks the decoded document and returns the value found at the end of the path.
+func lookupJSONPath(doc interface{}, steps []jsonPathStep) (interface{}, bool) {
+	current := doc
+	for _, step := range steps {
+		if step.index == jsonPathField {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[step.field]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := current.([]interface{})
+		if !ok || step.index == jsonPathWildcard || step.index >= len(arr) {
+			return nil, false
+		}
+		current = arr[step.index]
+	}
+	return current, true
+}
+
+// spliceJSONPath removes the field or array element found at the end of the path from an encoded document, along with
+// its separator, and reports whether it existed. The other bytes are left untouched: the remaining fields keep their order,
+// spacing and escaping. The document must be valid JSON, the bytes following its first value being ignored.
+func spliceJSONPath(value []byte, steps []jsonPathStep) ([]byte, bool) {
+	i := skipJSONSpace(value, 0)
+	for n, step := range steps {
+		if i >= len(value) || (step.index == jsonPathField && value[i] != '{') || (step.index != jsonPathField && value[i] != '[') {
+			return value, false
+		}
+		members := jsonMembers(value, i)
+		k := matchJSONMember(members, step)
+		if k < 0 {
+			return value, false
+		}
+		if n < len(steps)-1 {
+			i = members[k].valueStart
+			continue
+		}
+
+		// The decoders keep the last of the duplicated fields, they are all removed like a decoded field would be
+		for k >= 0 {
+			value = removeJSONMember(value, members, k)
+			if step.index != jsonPathField {
+				break
+			}
+			members = jsonMembers(value, i)
+			k = matchJSONMember(members, step)
+		}
+		return value, true
+	}
+	return value, false
+}
+
+// replaceJSONPath replaces every value found at the end of the path in an encoded document, expanding the wildcards, by the
+// bytes fn returns for it. Like spliceJSONPath, it leaves the other bytes untouched and expects a valid document. A duplicated
+// field is replaced every time it appears.
+func replaceJSONPath(value []byte, steps []jsonPathStep, fn func([]byte) []byte) []byte {
+	if len(steps) == 0 {
+		return value
+	}
+	return replaceJSONMembers(value, skipJSONSpace(value, 0), steps, fn)
+}
+
+// replaceJSONMembers replaces the values found at the end of the path in the object or array starting at value[i].
+func replaceJSONMembers(value []byte, i int, steps []jsonPathStep, fn func([]byte) []byte) []byte {
+	step := steps[0]
+	if i >= len(value) || (step.index == jsonPathField && value[i] != '{') || (step.index != jsonPathField && value[i] != '[') {
+		return value
+	}
+
+	// The members are replaced from the last one, so that the positions of the previous ones stay valid
+	members := jsonMembers(value, i)
+	for k := len(members) - 1; k >= 0; k-- {
+		m := members[k]
+		switch {
+		case step.index == jsonPathField && m.key != step.field:
+			continue
+		case step.index >= 0 && step.index != k:
+			continue
+		case len(steps) > 1:
+			value = replaceJSONMembers(value, m.valueStart, steps[1:], fn)
+			continue
+		}
+
+		replaced := fn(value[m.valueStart:m.end])
+		spliced := make([]byte, 0, len(value)-(m.end-m.valueStart)+len(replaced))
+		spliced = append(spliced, value[:m.valueStart]...)
+		spliced = append(spliced, replaced...)
+		value = append(spliced, value[m.end:]...)
+	}
+	return value
+}
+
+// jsonMember is the position of a member of an encoded object or array: its start, key included, and the bounds of its value.
+type jsonMember struct {
+	key        string
+	start      int
+	valueStart int
+	end        int
+}
+
+// jsonMembers returns the members of the valid object or array starting at value[i], the elements of an array having no key.
+func jsonMembers(value []byte, i int) []jsonMember {
+	object := value[i] == '{'
+	closing := byte(']')
+	if object {
+		closing = '}'
+	}
+
+	var members []jsonMember
+	i = skipJSONSpace(value, i+1)
+	for value[i] != closing {
+		m := jsonMember{start: i}
+		if object {
+			keyEnd := skipJSONValue(value, i)
+			_ = json.Unmarshal(value[i:keyEnd], &m.key)
+			// Skips the colon
+			i = skipJSONSpace(value, skipJSONSpace(value, keyEnd)+1)
+		}
+		m.valueStart = i
+		m.end = skipJSONValue(value, i)
+		members = append(members, m)
+
+		i = skipJSONSpace(value, m.end)
+		if value[i] == ',' {
+			i = skipJSONSpace(value, i+1)
+		}
+	}
+	return members
+}
+
+// matchJSONMember returns the index of the member selected by the step, the last one for a duplicated field, or -1.
+func matchJSONMember(members []jsonMember, step jsonPathStep) int {
+	if step.index != jsonPathField {
+		if step.index == jsonPathWildcard || step.index >= len(members) {
+			return -1
+		}
+		return step.index
+	}
+	for k := len(members) - 1; k >= 0; k-- {
+		if members[k].key == step.field {
+			return k
+		}
+	}
+	return -1
+}
+
+// removeJSONMember returns a copy of the document without a member, the separator following it being removed too,
+// or the one preceding it for the last member.
+func removeJSONMember(value []byte, members []jsonMember, k int) []byte {
+	from, to := members[k].start, members[k].end
+	switch {
+	case k < len(members)-1:
+		to = members[k+1].start
+	case k > 0:
+		from = members[k-1].end
+	}
+	spliced := make([]byte, 0, len(value)-(to-from))
+	spliced = append(spliced, value[:from]...)
+	return append(spliced, value[to:]...)
+}
+
+// skipJSONSpace returns the index of the first byte from value[i] which is not a JSON whitespace.
+func skipJSONSpace(value []byte, i int) int {
+	for i < len(value) && (value[i] == ' ' || value[i] == '\t' || value[i] == '\n' || value[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipJSONValue returns the index following the valid value starting at value[i].
+func skipJSONValue(value []byte, i int) int {
+	depth := 0
+	for ; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			for i++; value[i] != '"'; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[':
+			depth++
+			continue
+		case '}', ']':
+			depth--
+		case ',', ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return i
+			}
+			continue
+		default:
+			// A scalar ends before the byte closing its container, or the end of the document
+			if depth == 0 && i+1 < len(value) && (value[i+1] == '}' || value[i+1] == ']') {
+				return i + 1
+			}
+			continue
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return i
+}
+
+// decodeJSON decodes a message value, keeping numbers as json.Number so they can be compared and re-encoded losslessly.
+func decodeJSON(value []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// jsonValueString returns the textual representation of a JSON scalar, as a user would type it on the command line.
+func jsonValueString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		b, _ := json.Marshal(value)
+		return string(b)
+	}
+}
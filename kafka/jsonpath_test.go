@@ -0,0 +1,147 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonPathTest struct {
+	path     string
+	document string
+	expected string
+	found    bool
+}
+
+var jsonPathTestCases = []jsonPathTest{
+	{
+		path:     "$.foo",
+		document: `{"foo":"bar"}`,
+		expected: "bar",
+		found:    true,
+	},
+	{
+		path:     "foo.bar",
+		document: `{"foo":{"bar":12345678901234567890}}`,
+		expected: "12345678901234567890",
+		found:    true,
+	},
+	{
+		path:     "foo[1][0]",
+		document: `{"foo":[[true],[false]]}`,
+		expected: "false",
+		found:    true,
+	},
+	{
+		path:     "foo.baz",
+		document: `{"foo":{"bar":1}}`,
+		found:    false,
+	},
+	{
+		path:     "foo.bar",
+		document: `{"foo":"bar"}`,
+		found:    false,
+	},
+	{
+		path:     "foo",
+		document: `{"foo":{"bar":null}}`,
+		expected: `{"bar":null}`,
+		found:    true,
+	},
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	for _, v := range jsonPathTestCases {
+		//Arrange
+		steps, err := parseJSONPath(v.path)
+		assert.NoError(t, err)
+		doc, err := decodeJSON([]byte(v.document))
+		assert.NoError(t, err)
+
+		//Act
+		actual, found := lookupJSONPath(doc, steps)
+
+		//Assert
+		assert.Equal(t, v.found, found, v.path)
+		if v.found {
+			assert.Equal(t, v.expected, jsonValueString(actual), v.path)
+		}
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, path := range []string{"", "$", "foo..bar", "foo[-1]", "foo[bar]"} {
+		//Act
+		_, err := parseJSONPath(path)
+
+		//Assert
+		assert.Error(t, err, path)
+	}
+}
+
+func TestReplaceJSONPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		document string
+		expected string
+	}{
+		{path: "$.b", document: `{"b":2,"a":1}`, expected: `{"b":"x","a":1}`},
+		{path: "$.a", document: `{ "c" : "x,}]" , "a" : [1, {"c": 2}] }`, expected: `{ "c" : "x,}]" , "a" : "x" }`},
+		{path: "$.a", document: `{"a": 1, "b": 2, "a": 3}`, expected: `{"a": "x", "b": 2, "a": "x"}`},
+		{path: "$.items[*].tags[0]", document: `{"items":[{"tags":["a","b"]},{"tags":[]},{"tags":["c"]}]}`, expected: `{"items":[{"tags":["x","b"]},{"tags":[]},{"tags":["x"]}]}`},
+		{path: "$.a[1]", document: "{\"a\": [10,\n\t20,\n\t30]}", expected: "{\"a\": [10,\n\t\"x\",\n\t30]}"},
+		{path: "$.a[3]", document: `{"a": [1, 2, 3]}`, expected: `{"a": [1, 2, 3]}`},
+		{path: "$.a.b", document: `{"a": [1, 2, 3]}`, expected: `{"a": [1, 2, 3]}`},
+		{path: "$.c", document: `{"a": 1}`, expected: `{"a": 1}`},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		steps, err := parseJSONPath(tt.path)
+		assert.NoError(t, err)
+
+		//Act
+		actual := replaceJSONPath([]byte(tt.document), steps, func([]byte) []byte { return []byte(`"x"`) })
+
+		//Assert
+		assert.Equal(t, tt.expected, string(actual), tt.path)
+	}
+}
+
+func TestSpliceJSONPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		document string
+		expected string
+		found    bool
+	}{
+		{path: "$.b", document: `{"a":1,"b":2,"c":3}`, expected: `{"a":1,"c":3}`, found: true},
+		{path: "$.a", document: `{ "a" : "x,}]" , "b" : [1, {"c": 2}] }`, expected: `{ "b" : [1, {"c": 2}] }`, found: true},
+		{path: "$.b", document: `{"a": true, "b": null}`, expected: `{"a": true}`, found: true},
+		{path: "$.a", document: `{"a": {"b": "\"}"}}`, expected: `{}`, found: true},
+		{path: "$.a", document: `{"a": 1, "b": 2, "a": 3}`, expected: `{"b": 2}`, found: true},
+		{path: "$.aé", document: `{"aé": 1, "b": "<&>"}`, expected: `{"b": "<&>"}`, found: true},
+		{path: "$.a.b[1]", document: "{\"a\": {\"b\": [10,\n\t20,\n\t30]}}", expected: "{\"a\": {\"b\": [10,\n\t30]}}", found: true},
+		{path: "$.a[2]", document: `{"a": [1, 2, -3.5e2]}`, expected: `{"a": [1, 2]}`, found: true},
+		{path: "$.a[3]", document: `{"a": [1, 2, 3]}`, expected: `{"a": [1, 2, 3]}`},
+		{path: "$.a[*]", document: `{"a": [1, 2, 3]}`, expected: `{"a": [1, 2, 3]}`},
+		{path: "$.a.b", document: `{"a": [1, 2, 3]}`, expected: `{"a": [1, 2, 3]}`},
+		{path: "$.c", document: `{"a": 1}`, expected: `{"a": 1}`},
+		{path: "$[0]", document: `[{"a": 1}, 2]`, expected: `[2]`, found: true},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		steps, err := parseJSONPath(tt.path)
+		assert.NoError(t, err)
+
+		//Act
+		actual, found := spliceJSONPath([]byte(tt.document), steps)
+
+		//Assert
+		assert.Equal(t, tt.found, found, tt.path)
+		assert.Equal(t, tt.expected, string(actual), tt.path)
+	}
+}
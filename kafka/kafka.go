@@ -1,13 +1,18 @@
 package kafka
 
 import (
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
 	cluster "github.com/bsm/sarama-cluster"
 )
 
+//ManualPartitioning can be given instead of a hasher to NewProducer, to produce the messages on the partition they specify
+const ManualPartitioning = "manual"
+
 //NewConsumer configures and returns a cluster-consumer
 func NewConsumer(from string, brokers []string, consumerGroup string) *cluster.Consumer {
 
@@ -33,31 +38,114 @@ func NewConsumer(from string, brokers []string, consumerGroup string) *cluster.C
 	return consumer
 }
 
-//NewProducer configures and returns an async producer
-func NewProducer(brokers []string, hasher, compressionType string) sarama.AsyncProducer {
+//Producer is an async producer handing the messages it failed to produce, and optionally the ones it produced, to callbacks
+type Producer struct {
+	sarama.AsyncProducer
+	onError func(*sarama.ProducerError)
+	done    sync.WaitGroup
+}
+
+//NewProducer configures and returns an async producer. maxMessageBytes is the max.message.bytes of the target topic, 0 keeping
+//the default of the producer. onError is called with every message that could not be produced, the errors are logged if it is nil.
+//onSuccess is called with every message produced, if it is not nil.
+func NewProducer(brokers []string, hasher, compressionType string, maxMessageBytes int, onError func(*sarama.ProducerError), onSuccess func(*sarama.ProducerMessage)) *Producer {
 
-	cfg := buildProducerConfig(hasher, compressionType)
+	cfg := buildProducerConfig(hasher, compressionType, maxMessageBytes)
+	cfg.Producer.Return.Successes = onSuccess != nil
 
 	producer, err := sarama.NewAsyncProducer(brokers, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	return wrapProducer(producer, onError, onSuccess)
+}
+
+func wrapProducer(producer sarama.AsyncProducer, onError func(*sarama.ProducerError), onSuccess func(*sarama.ProducerMessage)) *Producer {
+	if onError == nil {
+		onError = func(err *sarama.ProducerError) {
+			log.Printf("Failed to produce message: %+v\n", err)
+		}
+	}
+	if onSuccess == nil {
+		onSuccess = func(*sarama.ProducerMessage) {}
+	}
+	p := &Producer{AsyncProducer: producer, onError: onError}
+	p.done.Add(2)
+
 	go func() {
+		defer p.done.Done()
 		for err := range producer.Errors() {
-			log.Printf("Failed to produce message: %+v\n", err)
+			onError(err)
 		}
 	}()
 
 	go func() {
-		for range producer.Successes() {
+		defer p.done.Done()
+		for msg := range producer.Successes() {
 			//Safety first! :D
 			//If return.Successes is set to true, not listening to this topic will
 			//prevent the application from cloning after a certain amount of events.
+			onSuccess(msg)
 		}
 	}()
 
-	return producer
+	return p
+}
+
+//Close flushes the pending messages, and returns once the callbacks have been called with every message
+func (p *Producer) Close() error {
+	err := p.AsyncProducer.Close()
+	p.done.Wait()
+
+	//The errors left when closing are returned by Close rather than sent to the errors channel
+	if errs, ok := err.(sarama.ProducerErrors); ok {
+		for _, e := range errs {
+			p.onError(e)
+		}
+		return nil
+	}
+	return err
+}
+
+//MaxReconnectBackoff caps the exponential backoff between two connection attempts
+const MaxReconnectBackoff = time.Minute
+
+//NewMirrorConsumer configures and returns a consumer resuming from the offsets committed by its group, for a consumer running
+//indefinitely: backoff is the delay before reconnecting to an unavailable broker, and the marked offsets are committed every
+//commitInterval. Unlike NewConsumer, it returns the connection errors, and its errors and notifications must be read by the caller.
+func NewMirrorConsumer(from string, brokers []string, consumerGroup string, backoff, commitInterval time.Duration) (*cluster.Consumer, error) {
+	return cluster.NewConsumer(brokers, consumerGroup, []string{from}, buildMirrorConsumerConfig(backoff, commitInterval))
+}
+
+//buildMirrorConsumerConfig returns the configuration of a mirror consumer. While it runs, the partitions and the metadata
+//are fetched again after backoff, doubled after each failed attempt up to MaxReconnectBackoff.
+func buildMirrorConsumerConfig(backoff, commitInterval time.Duration) *cluster.Config {
+	cfg := buildConsumerConfig()
+
+	//The rebalances of the group are retried after Metadata.Retry.Backoff, which cannot grow
+	cfg.Consumer.Retry.Backoff = backoff
+	cfg.Metadata.Retry.Backoff = backoff
+	cfg.Consumer.Retry.BackoffFunc = func(retries int) time.Duration {
+		return ExponentialBackoff(backoff, retries)
+	}
+	cfg.Metadata.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		return ExponentialBackoff(backoff, retries)
+	}
+	cfg.Consumer.Offsets.CommitInterval = commitInterval
+	return cfg
+}
+
+//ExponentialBackoff returns the delay before the given attempt, the first one being attempted after backoff, doubled after
+//each attempt up to MaxReconnectBackoff
+func ExponentialBackoff(backoff time.Duration, attempt int) time.Duration {
+	for i := 1; i < attempt && backoff < MaxReconnectBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxReconnectBackoff {
+		return MaxReconnectBackoff
+	}
+	return backoff
 }
 
 func buildConsumerConfig() *cluster.Config {
@@ -77,7 +165,7 @@ func buildConsumerConfig() *cluster.Config {
 	return cfg
 }
 
-func buildProducerConfig(hasher, compressionType string) *sarama.Config {
+func buildProducerConfig(hasher, compressionType string, maxMessageBytes int) *sarama.Config {
 
 	cfg := sarama.NewConfig()
 
@@ -87,8 +175,10 @@ func buildProducerConfig(hasher, compressionType string) *sarama.Config {
 	cfg.Producer.Return.Errors = true
 	cfg.Producer.RequiredAcks = sarama.WaitForLocal
 
-	// Since producer messages size limits are best-effort only, we set the sarama absolute hard limit ti 1M
-	sarama.MaxRequestSize = 1024 * 1024
+	// The producer rejects the records, and flushes the batches, larger than the target topic accepts
+	if maxMessageBytes > 0 {
+		cfg.Producer.MaxMessageBytes = MaxRecordSize(maxMessageBytes)
+	}
 
 	// Increasing this value will greatly increase the cloning speed.
 	// However, with MaxOpenRequests > 1, the order of the cloned messages is not guaranteed.
@@ -108,9 +198,88 @@ func buildProducerConfig(hasher, compressionType string) *sarama.Config {
 		cfg.Producer.Compression = sarama.CompressionLZ4
 	}
 
-	if hasher == "murmur2" {
-		cfg.Producer.Partitioner = sarama.NewCustomHashPartitioner(MurmurHasher)
-	}
+	cfg.Producer.Partitioner = partitionerConstructor(hasher)
 
 	return cfg
 }
+
+//partitionerConstructor returns the partitioner of a hasher, FNV-1a being sarama's default
+func partitionerConstructor(hasher string) sarama.PartitionerConstructor {
+	switch hasher {
+	case "murmur2":
+		return sarama.NewCustomHashPartitioner(MurmurHasher)
+	case "crc32":
+		return NewCRC32Partitioner
+	case "consistent_random":
+		return NewConsistentRandomPartitioner
+	case "round-robin":
+		return sarama.NewRoundRobinPartitioner
+	case "random":
+		return NewRandomPartitioner
+	case "sticky":
+		return NewStickyPartitioner
+	case ManualPartitioning:
+		return sarama.NewManualPartitioner
+	}
+	return sarama.NewHashPartitioner
+}
+
+//isHasher returns true if the hasher is known by partitionerConstructor, which falls back to FNV-1a otherwise
+func isHasher(hasher string) bool {
+	switch hasher {
+	case "murmur2", "FNV-1a", "crc32", "consistent_random", "round-robin", "random", "sticky", ManualPartitioning:
+		return true
+	}
+	return false
+}
+
+//NewPartitioner returns the partitioner used by the producer for the given hasher,
+//to compute the partition of a message without producing it
+func NewPartitioner(hasher, topic string) sarama.Partitioner {
+	return partitionerConstructor(hasher)(topic)
+}
+
+var (
+	//ErrNullKey is returned by PartitionFor for the keys the clients do not hash: they spread the messages without key over the
+	//partitions (round-robin or sticky for the JVM clients, random for sarama and librdkafka's consistent_random)
+	ErrNullKey = errors.New("messages without key are not partitioned by hash, the producers spread them over the partitions")
+	//ErrNotKeyHasher is returned by PartitionFor for the hashers which do not place the messages by key
+	ErrNotKeyHasher = errors.New("the hasher does not place the messages by key")
+
+	errInvalidPartitionCount = errors.New("the number of partitions must be at least 1")
+	errUnknownHasher         = errors.New("unknown hasher")
+)
+
+//PartitionFor returns the partition a producer using the hasher places a key on, for a topic with the given number of
+//partitions. A nil key, or an empty one with consistent_random, is not hashed by the clients: ErrNullKey is returned,
+//except with crc32 which places the nil keys on partition 0 like NewCRC32Partitioner.
+func PartitionFor(key []byte, hasher string, partitions int32) (int32, error) {
+	switch {
+	case partitions < 1:
+		return -1, errInvalidPartitionCount
+	case !isHasher(hasher):
+		return -1, errUnknownHasher
+	case hasher == "round-robin" || hasher == "random" || hasher == ManualPartitioning:
+		return -1, ErrNotKeyHasher
+	case key == nil && hasher == "crc32":
+		return 0, nil
+	case key == nil || (len(key) == 0 && hasher == "consistent_random"):
+		return -1, ErrNullKey
+	}
+	return NewPartitioner(hasher, "").Partition(&sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}, partitions)
+}
+
+//PartitionCount returns the number of partitions of a topic
+func PartitionCount(brokers []string, topic string) (int32, error) {
+	client, err := sarama.NewClient(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return 0, err
+	}
+	return int32(len(partitions)), nil
+}
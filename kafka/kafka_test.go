@@ -32,19 +32,145 @@ func TestBuildConsumerConfig(t *testing.T) {
 	assert.Equal(t, cfg.Consumer.Fetch.Min, int32(1024*10))
 }
 
+func TestBuildMirrorConsumerConfig(t *testing.T) {
+	//Act
+	cfg := buildMirrorConsumerConfig(2*time.Second, 5*time.Second)
+
+	//Assert
+	assert.Equal(t, cfg.Consumer.Offsets.CommitInterval, 5*time.Second)
+	assert.Equal(t, cfg.Metadata.Retry.Backoff, 2*time.Second)
+	assert.Equal(t, cfg.Consumer.Retry.BackoffFunc(1), 2*time.Second)
+	assert.Equal(t, cfg.Consumer.Retry.BackoffFunc(3), 8*time.Second)
+	assert.Equal(t, cfg.Metadata.Retry.BackoffFunc(2, 3), 4*time.Second)
+	assert.Equal(t, cfg.Consumer.Retry.BackoffFunc(100), MaxReconnectBackoff)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		backoff  time.Duration
+		attempt  int
+		expected time.Duration
+	}{
+		{backoff: time.Second, attempt: 0, expected: time.Second},
+		{backoff: time.Second, attempt: 1, expected: time.Second},
+		{backoff: time.Second, attempt: 4, expected: 8 * time.Second},
+		{backoff: 40 * time.Second, attempt: 2, expected: MaxReconnectBackoff},
+		{backoff: 2 * time.Minute, attempt: 1, expected: MaxReconnectBackoff},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual := ExponentialBackoff(tt.backoff, tt.attempt)
+
+		//Assert
+		assert.Equal(t, tt.expected, actual)
+	}
+}
+
 func TestBuildProducerConfig(t *testing.T) {
 	//Arrange
 	hasher := "murmur2"
 	compressionType := "gzip"
 
 	//Act
-	cfg := buildProducerConfig(hasher, compressionType)
+	cfg := buildProducerConfig(hasher, compressionType, 0)
 
 	//Assert
 	assert.Equal(t, cfg.Version, sarama.V1_0_0_0)
+	assert.Equal(t, cfg.Producer.MaxMessageBytes, sarama.NewConfig().Producer.MaxMessageBytes)
 	assert.Equal(t, cfg.Producer.Return.Successes, false)
 	assert.Equal(t, cfg.Producer.Return.Errors, true)
 	assert.Equal(t, cfg.Producer.RequiredAcks, sarama.WaitForLocal)
 	assert.Equal(t, cfg.Net.MaxOpenRequests, 1)
 	assert.Equal(t, cfg.Producer.Flush.Frequency, 100*time.Millisecond)
 }
+
+func TestBuildProducerConfigMaxMessageBytes(t *testing.T) {
+	//Arrange
+	maxRequestSize := sarama.MaxRequestSize
+
+	//Act
+	cfg := buildProducerConfig("murmur2", "none", 5*1024*1024)
+
+	//Assert
+	assert.Equal(t, cfg.Producer.MaxMessageBytes, 5*1024*1024-recordBatchOverhead)
+	assert.Equal(t, sarama.MaxRequestSize, maxRequestSize)
+}
+
+func TestBuildProducerConfigManualPartitioning(t *testing.T) {
+	//Act
+	cfg := buildProducerConfig(ManualPartitioning, "none", 0)
+	partitioner := cfg.Producer.Partitioner("foo")
+	partition, err := partitioner.Partition(&sarama.ProducerMessage{Partition: 3}, 4)
+
+	//Assert
+	assert.Equal(t, cfg.Producer.Compression, sarama.CompressionNone)
+	assert.Equal(t, partitioner.RequiresConsistency(), true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, partition, int32(3))
+}
+
+func TestNewPartitioner(t *testing.T) {
+	tests := []struct {
+		hasher   string
+		key      string
+		expected int32
+	}{
+		{hasher: "murmur2", key: "foo", expected: 2},
+		{hasher: "murmur2", key: "foobar", expected: 0},
+		{hasher: "murmur2", key: "42", expected: 1},
+		{hasher: "FNV-1a", key: "foo", expected: 1},
+		{hasher: "FNV-1a", key: "foobar", expected: 0},
+		{hasher: "FNV-1a", key: "42", expected: 2},
+		{hasher: "crc32", key: "foo", expected: 2},
+		{hasher: "crc32", key: "foobar", expected: 2},
+		{hasher: "consistent_random", key: "42", expected: 2},
+		{hasher: "sticky", key: "foo", expected: 2},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		partitioner := NewPartitioner(tt.hasher, "topic")
+
+		//Act
+		partition, err := partitioner.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder(tt.key)}, 3)
+
+		//Assert
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, partition, "%s(%s)", tt.hasher, tt.key)
+	}
+}
+
+func TestPartitionFor(t *testing.T) {
+	tests := []struct {
+		key        []byte
+		hasher     string
+		partitions int32
+		expected   int32
+		err        error
+	}{
+		{key: []byte("foo"), hasher: "murmur2", partitions: 3, expected: 2},
+		{key: []byte("foobar"), hasher: "murmur2", partitions: 3, expected: 0},
+		{key: []byte("42"), hasher: "FNV-1a", partitions: 3, expected: 2},
+		{key: []byte("foo"), hasher: "crc32", partitions: 10, expected: 9},
+		{key: []byte("foo"), hasher: "sticky", partitions: 3, expected: 2},
+		{key: []byte{}, hasher: "murmur2", partitions: 3, expected: int32(toPositive(murmur2(nil)) % 3)},
+		{key: []byte{}, hasher: "crc32", partitions: 3, expected: 0},
+		{key: nil, hasher: "murmur2", partitions: 3, expected: -1, err: ErrNullKey},
+		{key: nil, hasher: "crc32", partitions: 3, expected: 0},
+		{key: nil, hasher: "sticky", partitions: 3, expected: -1, err: ErrNullKey},
+		{key: []byte("foo"), hasher: "fnv1a", partitions: 3, expected: -1, err: errUnknownHasher},
+		{key: []byte{}, hasher: "consistent_random", partitions: 3, expected: -1, err: ErrNullKey},
+		{key: []byte("foo"), hasher: "round-robin", partitions: 3, expected: -1, err: ErrNotKeyHasher},
+		{key: []byte("foo"), hasher: "murmur2", partitions: 0, expected: -1, err: errInvalidPartitionCount},
+	}
+
+	for _, tt := range tests {
+		//Act
+		actual, err := PartitionFor(tt.key, tt.hasher, tt.partitions)
+
+		//Assert
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.expected, actual)
+	}
+}
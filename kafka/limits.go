@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+//DefaultMaxMessageBytes is the default max.message.bytes of kafka, assumed when the configuration of a topic cannot be described
+const DefaultMaxMessageBytes = 1000012
+
+const (
+	//recordBatchOverhead is the size of the header of a record batch, which max.message.bytes accounts for
+	recordBatchOverhead = 61
+	//maxRecordOverhead bounds the varints framing a record, the way the producer computes it
+	maxRecordOverhead = 5*binary.MaxVarintLen32 + binary.MaxVarintLen64 + 1
+	//maxHeaderOverhead bounds the varints framing a header
+	maxHeaderOverhead = 2 * binary.MaxVarintLen32
+)
+
+var errMaxMessageBytesNotFound = errors.New("max.message.bytes not found in the topic configuration")
+
+//MaxMessageBytes returns the effective max.message.bytes of a topic: its own configuration, or the broker default it inherits
+func MaxMessageBytes(brokers []string, topic string) (int, error) {
+	admin, err := sarama.NewClusterAdmin(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return 0, err
+	}
+	defer admin.Close()
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"max.message.bytes"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if entry.Name == "max.message.bytes" {
+			return strconv.Atoi(entry.Value)
+		}
+	}
+	return 0, errMaxMessageBytesNotFound
+}
+
+//MaxRecordSize returns the size of the largest record accepted by a topic with the given max.message.bytes, alone in its batch
+func MaxRecordSize(maxMessageBytes int) int {
+	return maxMessageBytes - recordBatchOverhead
+}
+
+//RecordSize bounds the size of a message once encoded as a record, the way the producer computes it to enforce its limit
+func RecordSize(msg *sarama.ProducerMessage) int {
+	size := maxRecordOverhead
+	if msg.Key != nil {
+		size += msg.Key.Length()
+	}
+	if msg.Value != nil {
+		size += msg.Value.Length()
+	}
+	for _, h := range msg.Headers {
+		size += len(h.Key) + len(h.Value) + maxHeaderOverhead
+	}
+	return size
+}
@@ -0,0 +1,51 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxMessageBytes(t *testing.T) {
+	//Arrange
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		//The mock describes every topic with a max.message.bytes of 1000000
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+	})
+
+	//Act
+	limit, err := MaxMessageBytes([]string{broker.Addr()}, "foo")
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1000000, limit)
+}
+
+func TestRecordSize(t *testing.T) {
+	tests := []struct {
+		msg      *sarama.ProducerMessage
+		expected int
+	}{
+		{msg: &sarama.ProducerMessage{}, expected: maxRecordOverhead},
+		{msg: &sarama.ProducerMessage{Key: sarama.StringEncoder("foo"), Value: sarama.ByteEncoder("foobar")}, expected: maxRecordOverhead + 9},
+		{
+			msg: &sarama.ProducerMessage{
+				Value:   sarama.StringEncoder("foo"),
+				Headers: []sarama.RecordHeader{{Key: []byte("bar"), Value: []byte("42")}},
+			},
+			expected: maxRecordOverhead + 8 + maxHeaderOverhead,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, RecordSize(tt.msg))
+	}
+}
@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+//Watermarks are the offsets bounding the records of a partition: the offset of its oldest record, and the offset of its next record
+type Watermarks struct {
+	Low  int64
+	High int64
+}
+
+//Empty returns true when the partition holds no record
+func (w Watermarks) Empty() bool {
+	return w.High <= w.Low
+}
+
+//TopicWatermarks returns the watermarks of every partition of a topic
+func TopicWatermarks(brokers []string, topic string) (map[int32]Watermarks, error) {
+	client, err := sarama.NewClient(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	marks := make(map[int32]Watermarks, len(partitions))
+	for _, p := range partitions {
+		low, err := client.GetOffset(topic, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		high, err := client.GetOffset(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		marks[p] = Watermarks{Low: low, High: high}
+	}
+	return marks, nil
+}
+
+//CommittedOffsets returns the offsets committed by a consumer group for the partitions of a topic, the offset of the next record
+//it consumes. The partitions without committed offset are left out.
+func CommittedOffsets(brokers []string, group, topic string) (map[int32]int64, error) {
+	client, err := sarama.NewClient(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &sarama.OffsetFetchRequest{Version: 1, ConsumerGroup: group}
+	for _, p := range partitions {
+		req.AddPartition(topic, p)
+	}
+	resp, err := coordinator.FetchOffset(req)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int32]int64, len(partitions))
+	for _, p := range partitions {
+		block := resp.GetBlock(topic, p)
+		if block == nil {
+			continue
+		}
+		if block.Err != sarama.ErrNoError {
+			return nil, block.Err
+		}
+		if block.Offset >= 0 {
+			offsets[p] = block.Offset
+		}
+	}
+	return offsets, nil
+}
+
+//CommitOffsets commits offsets for a consumer group on the partitions of a topic. The group must have no active member,
+//the coordinator rejecting the commits of a non-member otherwise.
+func CommitOffsets(brokers []string, group, topic string, offsets map[int32]int64) error {
+	client, err := sarama.NewClient(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return err
+	}
+
+	req := &sarama.OffsetCommitRequest{
+		Version:                 2,
+		ConsumerGroup:           group,
+		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
+		RetentionTime:           -1,
+	}
+	for p, offset := range offsets {
+		req.AddBlock(topic, p, offset, 0, "")
+	}
+	resp, err := coordinator.CommitOffset(req)
+	if err != nil {
+		return err
+	}
+	for _, errs := range resp.Errors {
+		for _, kerr := range errs {
+			if kerr != sarama.ErrNoError {
+				return kerr
+			}
+		}
+	}
+	return nil
+}
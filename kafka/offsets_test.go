@@ -0,0 +1,94 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicWatermarks(t *testing.T) {
+	//Arrange
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		//Kafka 1.0 clients send version 1 requests
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).
+			SetOffset("foo", 0, sarama.OffsetNewest, 42).
+			SetOffset("foo", 1, sarama.OffsetOldest, 7).
+			SetOffset("foo", 1, sarama.OffsetNewest, 7),
+	})
+
+	//Act
+	marks, err := TopicWatermarks([]string{broker.Addr()}, "foo")
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]Watermarks{0: {Low: 3, High: 42}, 1: {Low: 7, High: 7}}, marks)
+	assert.False(t, marks[0].Empty())
+	assert.True(t, marks[1].Empty())
+}
+
+func TestCommittedOffsets(t *testing.T) {
+	//Arrange
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).SetCoordinator(sarama.CoordinatorGroup, "group", broker),
+		//A partition without committed offset has an offset of -1
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("group", "foo", 0, 42, "", sarama.ErrNoError).
+			SetOffset("group", "foo", 1, -1, "", sarama.ErrNoError),
+	})
+
+	//Act
+	offsets, err := CommittedOffsets([]string{broker.Addr()}, "group", "foo")
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]int64{0: 42}, offsets)
+}
+
+func TestCommitOffsets(t *testing.T) {
+	tests := []struct {
+		kerr     sarama.KError
+		expected error
+	}{
+		{kerr: sarama.ErrNoError, expected: nil},
+		//The coordinator rejects the commits while the group has active members
+		{kerr: sarama.ErrUnknownMemberId, expected: sarama.ErrUnknownMemberId},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		broker := sarama.NewMockBroker(t, 1)
+		broker.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest":        sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()).SetLeader("foo", 0, broker.BrokerID()),
+			"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).SetCoordinator(sarama.CoordinatorGroup, "group", broker),
+			"OffsetCommitRequest":    sarama.NewMockOffsetCommitResponse(t).SetError("group", "foo", 0, tt.kerr),
+		})
+
+		//Act
+		err := CommitOffsets([]string{broker.Addr()}, "group", "foo", map[int32]int64{0: 42})
+		broker.Close()
+
+		//Assert
+		if tt.expected == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package kafka
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+//stickyBatchBytes is the default batch.size of the JVM producer: the sticky partitioner moves to another partition once
+//that many bytes of messages without key were sent to the current one, the way the JVM producer does when a batch is full
+const stickyBatchBytes = 16384
+
+//NewCRC32Partitioner returns a partitioner compatible with the consistent partitioner of librdkafka: the partition is
+//the CRC32 of the key modulo the number of partitions, the messages without key going to partition 0 (the CRC32 of nothing).
+func NewCRC32Partitioner(topic string) sarama.Partitioner {
+	return &crc32Partitioner{}
+}
+
+//NewConsistentRandomPartitioner returns a partitioner compatible with the consistent_random partitioner of librdkafka,
+//the default one: the messages with a key are partitioned like NewCRC32Partitioner, the other ones randomly.
+func NewConsistentRandomPartitioner(topic string) sarama.Partitioner {
+	return &crc32Partitioner{random: newRandomPartitioner(newSource())}
+}
+
+//NewRandomPartitioner returns a partitioner picking a random partition for every message
+func NewRandomPartitioner(topic string) sarama.Partitioner {
+	return newRandomPartitioner(newSource())
+}
+
+//NewStickyPartitioner returns a partitioner compatible with the default partitioner of the JVM producer since kafka 2.4:
+//the messages with a key are partitioned with murmur2, the other ones stick to a random partition until a batch worth of
+//them was sent there.
+func NewStickyPartitioner(topic string) sarama.Partitioner {
+	return newStickyPartitioner(newSource())
+}
+
+func newSource() rand.Source {
+	return rand.NewSource(time.Now().UnixNano())
+}
+
+type crc32Partitioner struct {
+	//random partitions the messages without key, they go to partition 0 if it is nil
+	random sarama.Partitioner
+}
+
+func (p *crc32Partitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	var key []byte
+	if msg.Key != nil {
+		var err error
+		if key, err = msg.Key.Encode(); err != nil {
+			return -1, err
+		}
+	}
+	if len(key) == 0 && p.random != nil {
+		return p.random.Partition(msg, numPartitions)
+	}
+	return int32(crc32.ChecksumIEEE(key) % uint32(numPartitions)), nil
+}
+
+func (p *crc32Partitioner) RequiresConsistency() bool {
+	return true
+}
+
+type randomPartitioner struct {
+	generator *rand.Rand
+}
+
+func newRandomPartitioner(source rand.Source) *randomPartitioner {
+	return &randomPartitioner{generator: rand.New(source)}
+}
+
+func (p *randomPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	return p.generator.Int31n(numPartitions), nil
+}
+
+func (p *randomPartitioner) RequiresConsistency() bool {
+	return false
+}
+
+type stickyPartitioner struct {
+	hash      sarama.Partitioner
+	generator *rand.Rand
+
+	partition int32
+	bytes     int
+}
+
+func newStickyPartitioner(source rand.Source) *stickyPartitioner {
+	return &stickyPartitioner{
+		hash:      sarama.NewCustomHashPartitioner(MurmurHasher)(""),
+		generator: rand.New(source),
+		partition: -1,
+	}
+}
+
+func (p *stickyPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if msg.Key != nil {
+		return p.hash.Partition(msg, numPartitions)
+	}
+
+	if p.partition < 0 || p.partition >= numPartitions || p.bytes >= stickyBatchBytes {
+		p.partition = p.next(numPartitions)
+		p.bytes = 0
+	}
+	p.bytes += RecordSize(msg)
+	return p.partition, nil
+}
+
+//next picks another random partition than the current one, if there is another one
+func (p *stickyPartitioner) next(numPartitions int32) int32 {
+	if numPartitions < 2 || p.partition < 0 || p.partition >= numPartitions {
+		return p.generator.Int31n(numPartitions)
+	}
+	partition := p.generator.Int31n(numPartitions - 1)
+	if partition >= p.partition {
+		partition++
+	}
+	return partition
+}
+
+func (p *stickyPartitioner) RequiresConsistency() bool {
+	return true
+}
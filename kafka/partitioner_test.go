@@ -0,0 +1,138 @@
+//+build unit
+
+package kafka
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+//partitionAll returns the partitions of the messages
+func partitionAll(t *testing.T, p sarama.Partitioner, msgs []*sarama.ProducerMessage, numPartitions int32) []int32 {
+	var partitions []int32
+	for _, msg := range msgs {
+		partition, err := p.Partition(msg, numPartitions)
+		assert.NoError(t, err)
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
+
+func keyed(keys ...string) []*sarama.ProducerMessage {
+	var msgs []*sarama.ProducerMessage
+	for _, key := range keys {
+		msgs = append(msgs, &sarama.ProducerMessage{Key: sarama.StringEncoder(key)})
+	}
+	return msgs
+}
+
+func TestCRC32Partitioner(t *testing.T) {
+	//Golden values computed with the CRC32 (IEEE) of librdkafka's consistent partitioner
+	tests := []struct {
+		partitions int32
+		expected   []int32
+	}{
+		{partitions: 3, expected: []int32{2, 2, 2, 0, 0, 2, 1}},
+		{partitions: 7, expected: []int32{2, 6, 3, 3, 0, 4, 2}},
+		{partitions: 10, expected: []int32{9, 9, 8, 9, 9, 5, 0}},
+	}
+
+	for _, tt := range tests {
+		//Arrange
+		p := NewCRC32Partitioner("foo")
+		msgs := keyed("foo", "foobar", "42", "customer-1", "customer-2", "customer-3", "hello")
+
+		//Act
+		actual := partitionAll(t, p, msgs, tt.partitions)
+
+		//Assert
+		assert.Equal(t, tt.expected, actual)
+		assert.True(t, p.RequiresConsistency())
+	}
+}
+
+func TestCRC32PartitionerWithoutKey(t *testing.T) {
+	//Arrange
+	p := NewCRC32Partitioner("foo")
+	msgs := []*sarama.ProducerMessage{{}, {Key: sarama.StringEncoder("")}}
+
+	//Act
+	actual := partitionAll(t, p, msgs, 6)
+
+	//Assert
+	assert.Equal(t, []int32{0, 0}, actual)
+}
+
+func TestConsistentRandomPartitioner(t *testing.T) {
+	//Arrange
+	p := &crc32Partitioner{random: newRandomPartitioner(rand.NewSource(42))}
+	msgs := []*sarama.ProducerMessage{{}, {Key: sarama.StringEncoder("")}, {Key: sarama.StringEncoder("foo")}, {}}
+
+	//Act
+	actual := partitionAll(t, p, msgs, 6)
+
+	//Assert
+	assert.Equal(t, []int32{5, 5, 5, 2}, actual)
+}
+
+func TestRoundRobinPartitioner(t *testing.T) {
+	//Arrange
+	p := NewPartitioner("round-robin", "foo")
+	msgs := keyed("foo", "foo", "foo", "bar", "bar")
+
+	//Act
+	actual := partitionAll(t, p, msgs, 3)
+
+	//Assert
+	assert.Equal(t, []int32{0, 1, 2, 0, 1}, actual)
+	assert.False(t, p.RequiresConsistency())
+}
+
+func TestRandomPartitioner(t *testing.T) {
+	//Arrange
+	p := newRandomPartitioner(rand.NewSource(42))
+	msgs := keyed("foo", "foo", "foo", "foo", "foo", "foo", "foo", "foo")
+
+	//Act
+	actual := partitionAll(t, p, msgs, 6)
+
+	//Assert
+	assert.Equal(t, []int32{5, 5, 2, 0, 1, 1, 3, 2}, actual)
+	assert.False(t, p.RequiresConsistency())
+}
+
+func TestStickyPartitioner(t *testing.T) {
+	//Arrange
+	p := newStickyPartitioner(rand.NewSource(42))
+	var msgs []*sarama.ProducerMessage
+	for i := 0; i < 6; i++ {
+		msgs = append(msgs, &sarama.ProducerMessage{Value: sarama.ByteEncoder(make([]byte, 8000))})
+	}
+	msgs = append(msgs, keyed("foo", "foobar", "42")...)
+
+	//Act
+	actual := partitionAll(t, p, msgs, 6)
+
+	//Assert
+	//A batch holds three 8000 bytes messages, the keyed messages are placed like murmur2 does
+	murmur2 := partitionAll(t, NewPartitioner("murmur2", "foo"), keyed("foo", "foobar", "42"), 6)
+	assert.Equal(t, append([]int32{5, 5, 5, 2, 2, 2}, murmur2...), actual)
+}
+
+func TestStickyPartitionerSinglePartition(t *testing.T) {
+	//Arrange
+	p := newStickyPartitioner(rand.NewSource(42))
+	var msgs []*sarama.ProducerMessage
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, &sarama.ProducerMessage{Value: sarama.ByteEncoder(make([]byte, 10000))})
+	}
+
+	//Act
+	actual := partitionAll(t, p, msgs, 1)
+
+	//Assert
+	assert.Equal(t, []int32{0, 0, 0, 0}, actual)
+}
@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+//probeFetchBytes is the size of the fetches of a probe, the first batch being returned whole whatever its size
+const probeFetchBytes = 64 * 1024
+
+//RecordProbe tells whether records are left in the partitions of a topic, without consuming them. A consumer reading a
+//partition up to an offset cannot tell it got there when the last offsets hold no record to deliver: compacted away, or
+//transaction markers.
+type RecordProbe struct {
+	client sarama.Client
+	topic  string
+}
+
+//NewRecordProbe returns the probe of a topic
+func NewRecordProbe(brokers []string, topic string) (*RecordProbe, error) {
+	client, err := sarama.NewClient(brokers, buildProducerConfig("", "none", 0))
+	if err != nil {
+		return nil, err
+	}
+	return &RecordProbe{client: client, topic: topic}, nil
+}
+
+//Remaining returns true if the partition holds a record, other than a transaction marker, from the offset from and before the offset to
+func (p *RecordProbe) Remaining(partition int32, from, to int64) (bool, error) {
+	for from < to {
+		leader, err := p.client.Leader(p.topic, partition)
+		if err != nil {
+			return false, err
+		}
+
+		req := &sarama.FetchRequest{Version: 4, MaxBytes: probeFetchBytes, Isolation: sarama.ReadUncommitted}
+		req.AddBlock(p.topic, partition, from, probeFetchBytes)
+		resp, err := leader.Fetch(req)
+		if err != nil {
+			return false, err
+		}
+		block := resp.GetBlock(p.topic, partition)
+		if block == nil {
+			return false, sarama.ErrIncompleteResponse
+		}
+		if block.Err != sarama.ErrNoError {
+			return false, block.Err
+		}
+
+		found, next := recordsIn(block.RecordsSet, from, to)
+		if found {
+			return true, nil
+		}
+		if next <= from {
+			//Nothing was fetched: the partition holds no record from the offset on
+			return false, nil
+		}
+		from = next
+	}
+	return false, nil
+}
+
+//recordsIn returns true if the fetched records hold one, other than a transaction marker, from the offset from and before the
+//offset to. Otherwise, it returns the offset following the fetched records, to fetch the next ones from.
+func recordsIn(sets []*sarama.Records, from, to int64) (bool, int64) {
+	next := from
+	for _, records := range sets {
+		switch {
+		case records.RecordBatch != nil:
+			batch := records.RecordBatch
+			if batch.PartialTrailingRecord {
+				//The batch did not fit in the fetch, it is fetched again from its offset, unless nothing came before it
+				return next == from, next
+			}
+			if !batch.Control {
+				for _, rec := range batch.Records {
+					if offset := batch.FirstOffset + rec.OffsetDelta; offset >= from && offset < to {
+						return true, next
+					}
+				}
+			}
+			if last := batch.LastOffset() + 1; last > next {
+				next = last
+			}
+
+		case records.MsgSet != nil:
+			for _, block := range records.MsgSet.Messages {
+				//The inner offsets of the compressed messages are relative to the offset of their wrapper from version 1 on.
+				//A wrapper holding no message only moves the next offset.
+				messages := block.Messages()
+				var base int64
+				if block.Msg.Version >= 1 && len(messages) > 0 {
+					base = block.Offset - messages[len(messages)-1].Offset
+				}
+				for _, msg := range messages {
+					if offset := base + msg.Offset; offset >= from && offset < to {
+						return true, next
+					}
+				}
+				if block.Offset+1 > next {
+					next = block.Offset + 1
+				}
+			}
+			if records.MsgSet.PartialTrailingMessage && next == from {
+				return true, next
+			}
+		}
+	}
+	return false, next
+}
+
+//Close closes the connections of the probe
+func (p *RecordProbe) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,96 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordsIn(t *testing.T) {
+	//Arrange
+	resp := &sarama.FetchResponse{}
+	resp.AddRecordBatch("foo", 0, nil, sarama.StringEncoder("bar"), 4, 1, true)
+	resp.AddControlRecord("foo", 0, 5, 1, sarama.ControlRecordCommit)
+	sets := resp.GetBlock("foo", 0).RecordsSet
+
+	tests := []struct {
+		name     string
+		from     int64
+		to       int64
+		expected bool
+		next     int64
+	}{
+		{name: "record", from: 4, to: 6, expected: true, next: 4},
+		{name: "record before from", from: 5, to: 6, expected: false, next: 6},
+		{name: "record from to on", from: 2, to: 4, expected: false, next: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Act
+			found, next := recordsIn(sets, tt.from, tt.to)
+
+			//Assert
+			assert.Equal(t, tt.expected, found)
+			assert.Equal(t, tt.next, next)
+		})
+	}
+}
+
+func TestRecordsInEmptyWrapper(t *testing.T) {
+	//Arrange
+	wrapper := &sarama.MessageBlock{Offset: 7, Msg: &sarama.Message{Version: 1, Codec: sarama.CompressionGZIP, Set: &sarama.MessageSet{}}}
+	record := &sarama.MessageBlock{Offset: 8, Msg: &sarama.Message{Version: 1, Value: []byte("bar")}}
+
+	tests := []struct {
+		name     string
+		blocks   []*sarama.MessageBlock
+		expected bool
+		next     int64
+	}{
+		{name: "empty wrapper alone", blocks: []*sarama.MessageBlock{wrapper}, expected: false, next: 8},
+		{name: "empty wrapper then record", blocks: []*sarama.MessageBlock{wrapper, record}, expected: true, next: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Act
+			found, next := recordsIn([]*sarama.Records{{MsgSet: &sarama.MessageSet{Messages: tt.blocks}}}, 7, 9)
+
+			//Assert
+			assert.Equal(t, tt.expected, found)
+			assert.Equal(t, tt.next, next)
+		})
+	}
+}
+
+func TestRecordProbeRemaining(t *testing.T) {
+	//Arrange
+	resp := &sarama.FetchResponse{Version: 4}
+	resp.AddRecordBatch("foo", 0, nil, sarama.StringEncoder("bar"), 4, 1, true)
+	resp.AddControlRecord("foo", 0, 5, 1, sarama.ControlRecordCommit)
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()),
+		"FetchRequest": sarama.NewMockWrapper(resp),
+	})
+	probe, err := NewRecordProbe([]string{broker.Addr()}, "foo")
+	assert.NoError(t, err)
+	defer probe.Close()
+
+	//Act
+	before, errBefore := probe.Remaining(0, 4, 6)
+	marker, errMarker := probe.Remaining(0, 5, 6)
+
+	//Assert
+	assert.NoError(t, errBefore)
+	assert.NoError(t, errMarker)
+	assert.True(t, before)
+	assert.False(t, marker)
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// Redaction actions
+const (
+	RedactHash      = "hash"
+	RedactNull      = "null"
+	RedactFakeEmail = "fake-email"
+	RedactFakeName  = "fake-name"
+	RedactFakeCard  = "fake-card"
+)
+
+var (
+	redactActions = []string{RedactHash, RedactNull, RedactFakeEmail, RedactFakeName, RedactFakeCard}
+
+	errInvalidRedactRule   = errors.New("invalid redaction rule, expected path:action")
+	errUnknownRedactAction = fmt.Errorf("unknown redaction action, possible values: %s", strings.Join(redactActions, ", "))
+	errRedactNotJSON       = errors.New("value is not JSON, it cannot be redacted")
+	errMissingRedactSecret = errors.New("a secret is required to redact, the values could be recovered by hashing candidates otherwise")
+
+	fakeFirstNames = []string{"Alex", "Charlie", "Dana", "Eden", "Jamie", "Kim", "Morgan", "Robin", "Sam", "Taylor"}
+	fakeLastNames  = []string{"Baker", "Carter", "Evans", "Fischer", "Garcia", "Keller", "Martin", "Meier", "Rossi", "Smith"}
+)
+
+type redactRule struct {
+	path   []jsonPathStep
+	action string
+}
+
+// Redactor masks personal data in JSON values before they are produced.
+//
+// Every action is deterministic: a given input is always replaced by the same output, so that
+// the redacted fields can still be joined on. The hashes are keyed with a secret, to prevent
+// recovering the original values by hashing candidates.
+// When the key is redacted as well, the same input key always gives the same output key, so the
+// records sharing a key keep landing on the same partition.
+type Redactor struct {
+	secret []byte
+	rules  []redactRule
+	key    string
+}
+
+// NewRedactor builds a redactor from rules written "path:action" (e.g. "$.customer.email:fake-email").
+// keyAction, if not empty, is the action applied to the whole message key. The secret can only be empty without rules nor keyAction.
+func NewRedactor(secret string, rules []string, keyAction string) (*Redactor, error) {
+	r := &Redactor{secret: []byte(secret)}
+
+	for _, rule := range rules {
+		i := strings.LastIndex(rule, ":")
+		if i <= 0 {
+			return nil, errInvalidRedactRule
+		}
+		if !isRedactAction(rule[i+1:]) {
+			return nil, errUnknownRedactAction
+		}
+		steps, err := parseJSONPath(rule[:i])
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, redactRule{path: steps, action: rule[i+1:]})
+	}
+
+	if keyAction != "" {
+		if !isRedactAction(keyAction) || keyAction == RedactNull {
+			// A null key would break the partitioning of the records
+			return nil, errUnknownRedactAction
+		}
+		r.key = keyAction
+	}
+
+	if secret == "" && (len(r.rules) > 0 || r.key != "") {
+		return nil, errMissingRedactSecret
+	}
+	return r, nil
+}
+
+func isRedactAction(action string) bool {
+	for _, a := range redactActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Transform implements Transformer. A message whose value is not JSON is rejected when there are value rules,
+// rather than risking to leak personal data.
+func (r *Redactor) Transform(msg *sarama.ProducerMessage) error {
+	if r.key != "" && msg.Key != nil {
+		key, err := encoded(msg.Key)
+		if err != nil {
+			return err
+		}
+		msg.Key = sarama.StringEncoder(jsonValueString(r.redact(r.key, string(key))))
+	}
+
+	if len(r.rules) == 0 || msg.Value == nil {
+		return nil
+	}
+	value, err := encoded(msg.Value)
+	if err != nil {
+		return err
+	}
+	if _, err := decodeJSON(value); err != nil {
+		return errRedactNotJSON
+	}
+
+	// The redacted values are replaced in place, the other fields keeping their order, spacing and escaping
+	for _, rule := range r.rules {
+		action := rule.action
+		value = replaceJSONPath(value, rule.path, func(raw []byte) []byte {
+			v, err := decodeJSON(raw)
+			if err != nil || v == nil {
+				return raw
+			}
+			// The redacted values are strings or null, which always encode
+			b, _ := encodeJSON(r.redact(action, v))
+			return b
+		})
+	}
+	msg.Value = sarama.ByteEncoder(value)
+	return nil
+}
+
+// encodeJSON encodes a redacted value. Unlike json.Marshal, the characters <, > and & are not escaped.
+func encodeJSON(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	// The encoder ends every document with a newline
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (r *Redactor) redact(action string, v interface{}) interface{} {
+	sum := r.sum(v)
+
+	switch action {
+	case RedactNull:
+		return nil
+	case RedactFakeEmail:
+		return fmt.Sprintf("user-%s@example.com", hex.EncodeToString(sum[:6]))
+	case RedactFakeName:
+		first := fakeFirstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(fakeFirstNames))]
+		last := fakeLastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(fakeLastNames))]
+		return first + " " + last
+	case RedactFakeCard:
+		return fakeCardNumber(sum)
+	default:
+		return hex.EncodeToString(sum)
+	}
+}
+
+// sum is the keyed hash of the textual representation of a value, objects and arrays included
+func (r *Redactor) sum(v interface{}) []byte {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(jsonValueString(v)))
+	return mac.Sum(nil)
+}
+
+// fakeCardNumber derives a 16-digit card number that passes the Luhn check, in the 4000 test range
+func fakeCardNumber(sum []byte) string {
+	digits := make([]int, 16)
+	digits[0], digits[1], digits[2], digits[3] = 4, 0, 0, 0
+	for i := 4; i < 15; i++ {
+		digits[i] = int(sum[i]) % 10
+	}
+
+	total := 0
+	for i := 0; i < 15; i++ {
+		d := digits[i]
+		// Starting from the check digit, every second digit is doubled
+		if i%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		total += d
+	}
+	digits[15] = (10 - total%10) % 10
+
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteByte(byte('0' + d))
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+//+build unit
+
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func redactedValue(t *testing.T, msg *sarama.ProducerMessage) map[string]interface{} {
+	b, err := msg.Value.Encode()
+	assert.NoError(t, err)
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &doc))
+	return doc
+}
+
+func TestRedactorTransform(t *testing.T) {
+	//Arrange
+	redactor, err := NewRedactor("secret", []string{
+		"$.email:fake-email",
+		"$.name:fake-name",
+		"$.payments[*].card:fake-card",
+		"$.phone:null",
+		"$.customerId:hash",
+		"$.missing:hash",
+	}, RedactHash)
+	assert.NoError(t, err)
+	value := `{"email":"foo@bar.com","name":"Foo Bar","payments":[{"card":"4111111111111111"},{"card":"5500000000000004"}],"phone":"+41790000000","customerId":42,"amount":12.5}`
+
+	//Act
+	first := &sarama.ProducerMessage{Key: sarama.StringEncoder("42"), Value: sarama.StringEncoder(value)}
+	second := &sarama.ProducerMessage{Key: sarama.StringEncoder("42"), Value: sarama.StringEncoder(value)}
+	firstErr := redactor.Transform(first)
+	secondErr := redactor.Transform(second)
+
+	//Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, first, second, "redaction must be deterministic")
+
+	doc := redactedValue(t, first)
+	assert.Regexp(t, `^user-[0-9a-f]{12}@example\.com$`, doc["email"])
+	assert.Regexp(t, `^[A-Z][a-z]+ [A-Z][a-z]+$`, doc["name"])
+	assert.Nil(t, doc["phone"])
+	assert.Equal(t, 12.5, doc["amount"])
+	assert.NotContains(t, doc, "missing")
+	assert.Len(t, doc["customerId"], 64)
+
+	key, _ := first.Key.Encode()
+	assert.Equal(t, doc["customerId"], string(key), "the key and the customerId must be hashed identically")
+
+	cards := doc["payments"].([]interface{})
+	assert.NotEqual(t, cards[0], cards[1])
+	for _, c := range cards {
+		card := c.(map[string]interface{})["card"].(string)
+		assert.Len(t, card, 16)
+		assert.True(t, luhnValid(card), card)
+	}
+}
+
+func luhnValid(number string) bool {
+	total := 0
+	for i := range number {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		total += d
+	}
+	return total%10 == 0
+}
+
+func TestRedactorKeepsText(t *testing.T) {
+	//Arrange
+	redactor, _ := NewRedactor("secret", []string{"email:null", "$.customer.name:fake-name"}, "")
+	msg := &sarama.ProducerMessage{Value: sarama.StringEncoder(`{"url": "/search?q=<b>&page=2", "email": "foo@bar.com", "amount": 1.50, "customer": {"name": "Foo Bar", "id": 42}}`)}
+
+	//Act
+	err := redactor.Transform(msg)
+
+	//Assert
+	assert.NoError(t, err)
+	name := redactedValue(t, msg)["customer"].(map[string]interface{})["name"].(string)
+	expected := `{"url": "/search?q=<b>&page=2", "email": null, "amount": 1.50, "customer": {"name": "` + name + `", "id": 42}}`
+	assert.Equal(t, sarama.ByteEncoder(expected), msg.Value)
+}
+
+func TestRedactorSecret(t *testing.T) {
+	//Arrange
+	foo, _ := NewRedactor("foo", []string{"email:hash"}, "")
+	bar, _ := NewRedactor("bar", []string{"email:hash"}, "")
+	fooMsg := &sarama.ProducerMessage{Key: sarama.StringEncoder("42"), Value: sarama.StringEncoder(`{"email":"foo@bar.com"}`)}
+	barMsg := &sarama.ProducerMessage{Key: sarama.StringEncoder("42"), Value: sarama.StringEncoder(`{"email":"foo@bar.com"}`)}
+
+	//Act
+	foo.Transform(fooMsg)
+	bar.Transform(barMsg)
+
+	//Assert
+	assert.NotEqual(t, redactedValue(t, fooMsg)["email"], redactedValue(t, barMsg)["email"])
+	assert.Equal(t, sarama.StringEncoder("42"), fooMsg.Key, "the key must be left untouched without a key action")
+}
+
+func TestRedactorNotJSON(t *testing.T) {
+	//Arrange
+	redactor, _ := NewRedactor("secret", []string{"email:hash"}, "")
+
+	//Act
+	err := redactor.Transform(&sarama.ProducerMessage{Value: sarama.StringEncoder("foo@bar.com")})
+	tombstoneErr := redactor.Transform(&sarama.ProducerMessage{Key: sarama.StringEncoder("42")})
+
+	//Assert
+	assert.Equal(t, errRedactNotJSON, err)
+	assert.NoError(t, tombstoneErr)
+}
+
+func TestNewRedactorErrors(t *testing.T) {
+	//Act
+	_, ruleErr := NewRedactor("", []string{"email"}, "")
+	_, actionErr := NewRedactor("", []string{"email:scramble"}, "")
+	_, pathErr := NewRedactor("", []string{"items[x]:hash"}, "")
+	_, keyErr := NewRedactor("", nil, RedactNull)
+	_, secretErr := NewRedactor("", []string{"email:hash"}, "")
+	_, keySecretErr := NewRedactor("", nil, RedactHash)
+	_, noRuleErr := NewRedactor("", nil, "")
+
+	//Assert
+	assert.Equal(t, errInvalidRedactRule, ruleErr)
+	assert.Equal(t, errUnknownRedactAction, actionErr)
+	assert.Error(t, pathErr)
+	assert.Equal(t, errUnknownRedactAction, keyErr)
+	assert.Equal(t, errMissingRedactSecret, secretErr)
+	assert.Equal(t, errMissingRedactSecret, keySecretErr)
+	assert.NoError(t, noRuleErr)
+}
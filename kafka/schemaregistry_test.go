@@ -0,0 +1,210 @@
+//+build unit
+
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRegistry is an in-process stand-in for the schema registry REST API
+type fakeRegistry struct {
+	mu       sync.Mutex
+	nextID   int
+	schemas  map[int]registrySchema
+	subjects map[string][]int
+	calls    int
+}
+
+func newFakeRegistry(firstID int) *fakeRegistry {
+	return &fakeRegistry{
+		nextID:   firstID,
+		schemas:  make(map[int]registrySchema),
+		subjects: make(map[string][]int),
+	}
+}
+
+func (f *fakeRegistry) add(subject string, s registrySchema) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, existing := range f.schemas {
+		if existing.Schema == s.Schema && existing.SchemaType == s.SchemaType {
+			for _, v := range f.subjects[subject] {
+				if v == id {
+					return id
+				}
+			}
+			f.subjects[subject] = append(f.subjects[subject], id)
+			return id
+		}
+	}
+	id := f.nextID
+	f.nextID++
+	f.schemas[id] = s
+	f.subjects[subject] = append(f.subjects[subject], id)
+	return id
+}
+
+func (f *fakeRegistry) version(subject string, id int) int {
+	for i, v := range f.subjects[subject] {
+		if v == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, _ := strconv.Atoi(parts[2])
+		f.mu.Lock()
+		s, ok := f.schemas[id]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error_code":40403,"message":"Schema not found"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "subjects" && parts[2] == "versions":
+		version, _ := strconv.Atoi(parts[3])
+		f.mu.Lock()
+		ids := f.subjects[parts[1]]
+		f.mu.Unlock()
+		if version < 1 || version > len(ids) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(f.schemas[ids[version-1]])
+
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions":
+		var s registrySchema
+		json.NewDecoder(r.Body).Decode(&s)
+		fmt.Fprintf(w, `{"id":%d}`, f.add(parts[1], s))
+
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "subjects":
+		var s registrySchema
+		json.NewDecoder(r.Body).Decode(&s)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for id, existing := range f.schemas {
+			if existing.Schema == s.Schema {
+				if v := f.version(parts[1], id); v > 0 {
+					fmt.Fprintf(w, `{"id":%d,"version":%d}`, id, v)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func wireFormat(id int, payload string) sarama.ByteEncoder {
+	return append([]byte{0, byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}, payload...)
+}
+
+func TestSchemaRemapperTransform(t *testing.T) {
+	//Arrange
+	source, target := newFakeRegistry(100), newFakeRegistry(1)
+	target.add("unrelated-value", registrySchema{Schema: `"string"`})
+	valueID := source.add("foo-value", registrySchema{Schema: `{"type":"record","name":"Foo","fields":[]}`})
+	keyID := source.add("foo-key", registrySchema{Schema: `"long"`})
+
+	sourceServer, targetServer := httptest.NewServer(source), httptest.NewServer(target)
+	defer sourceServer.Close()
+	defer targetServer.Close()
+
+	sourceRegistry, err := NewSchemaRegistry(sourceServer.URL)
+	assert.NoError(t, err)
+	targetRegistry, err := NewSchemaRegistry(targetServer.URL + "/")
+	assert.NoError(t, err)
+	remapper := NewSchemaRemapper(sourceRegistry, targetRegistry, true)
+
+	//Act
+	var msgs []*sarama.ProducerMessage
+	for i := 0; i < 3; i++ {
+		msg := &sarama.ProducerMessage{Topic: "bar", Key: wireFormat(keyID, "k"), Value: wireFormat(valueID, "payload")}
+		assert.NoError(t, remapper.Transform(msg))
+		msgs = append(msgs, msg)
+	}
+
+	//Assert
+	assert.Equal(t, wireFormat(2, "payload"), msgs[2].Value)
+	assert.Equal(t, wireFormat(3, "k"), msgs[2].Key)
+	assert.Equal(t, []int{2}, target.subjects["bar-value"])
+	assert.Equal(t, []int{3}, target.subjects["bar-key"])
+	assert.Equal(t, 2, source.calls, "schemas should only be fetched once")
+}
+
+func TestSchemaRemapperReferences(t *testing.T) {
+	//Arrange
+	source, target := newFakeRegistry(10), newFakeRegistry(1)
+	source.add("address", registrySchema{Schema: `message Address {}`, SchemaType: "PROTOBUF"})
+	source.add("address", registrySchema{Schema: `message Address { string city = 1; }`, SchemaType: "PROTOBUF"})
+	valueID := source.add("customers-value", registrySchema{
+		Schema:     `import "address.proto"; message Customer { Address address = 1; }`,
+		SchemaType: "PROTOBUF",
+		References: []schemaReference{{Name: "address.proto", Subject: "address", Version: 2}},
+	})
+
+	sourceServer, targetServer := httptest.NewServer(source), httptest.NewServer(target)
+	defer sourceServer.Close()
+	defer targetServer.Close()
+
+	sourceRegistry, _ := NewSchemaRegistry(sourceServer.URL)
+	targetRegistry, _ := NewSchemaRegistry(targetServer.URL)
+	remapper := NewSchemaRemapper(sourceRegistry, targetRegistry, false)
+	msg := &sarama.ProducerMessage{Topic: "customers", Key: sarama.StringEncoder("42"), Value: wireFormat(valueID, "\x00payload")}
+
+	//Act
+	err := remapper.Transform(msg)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, wireFormat(2, "\x00payload"), msg.Value)
+	assert.Equal(t, sarama.StringEncoder("42"), msg.Key)
+	assert.Equal(t, []schemaReference{{Name: "address.proto", Subject: "address", Version: 1}}, target.schemas[2].References)
+}
+
+func TestSchemaRemapperErrors(t *testing.T) {
+	//Arrange
+	source, target := newFakeRegistry(1), newFakeRegistry(1)
+	sourceServer, targetServer := httptest.NewServer(source), httptest.NewServer(target)
+	defer sourceServer.Close()
+	defer targetServer.Close()
+
+	sourceRegistry, _ := NewSchemaRegistry(sourceServer.URL)
+	targetRegistry, _ := NewSchemaRegistry(targetServer.URL)
+	remapper := NewSchemaRemapper(sourceRegistry, targetRegistry, false)
+
+	//Act
+	notWireFormatErr := remapper.Transform(&sarama.ProducerMessage{Topic: "foo", Value: sarama.StringEncoder("plain")})
+	unknownSchemaErr := remapper.Transform(&sarama.ProducerMessage{Topic: "foo", Value: wireFormat(42, "payload")})
+	tombstoneErr := remapper.Transform(&sarama.ProducerMessage{Topic: "foo"})
+
+	//Assert
+	assert.Equal(t, errNotWireFormat, notWireFormatErr)
+	assert.Error(t, unknownSchemaErr)
+	assert.Contains(t, unknownSchemaErr.Error(), "Schema not found")
+	assert.NoError(t, tombstoneErr)
+}
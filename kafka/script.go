@@ -0,0 +1,255 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+)
+
+const scriptEntrypoint = "transform"
+
+var (
+	errMissingEntrypoint  = fmt.Errorf("script must define a %s(record) function", scriptEntrypoint)
+	errInvalidScriptValue = errors.New("script must return None, a record or a list of records")
+	errInvalidRecordField = errors.New("invalid record field")
+)
+
+// Script runs a Starlark function on every message, allowing logic that goes beyond the built-in transformers.
+//
+// The script must define a transform(record) function. The record is a dict holding the "topic", "key", "value",
+// "headers" (a list of (name, value) tuples), "timestamp" (ms since epoch), "partition" and "offset" of the consumed message.
+// The function returns None to drop the message, a record, or a list of records to produce several messages.
+// The returned records may override the "topic" to route the messages to another target topic. The "timestamp",
+// "partition" and "offset" of the consumed message are read-only: a returned record may leave them out, but not change them.
+// The json module (json.decode, json.encode) is available to the scripts.
+type Script struct {
+	name      string
+	mu        sync.Mutex
+	thread    *starlark.Thread
+	transform starlark.Callable
+}
+
+// NewScript loads and initializes the script stored in the given file
+func NewScript(path string) (*Script, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newScript(filepath.Base(path), src)
+}
+
+func newScript(name string, src []byte) (*Script, error) {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Printf("%s: %s", name, msg)
+		},
+	}
+
+	predeclared := starlark.StringDict{"json": json.Module}
+	globals, err := starlark.ExecFile(thread, name, src, predeclared)
+	if err != nil {
+		return nil, err
+	}
+
+	transform, ok := globals[scriptEntrypoint].(starlark.Callable)
+	if !ok {
+		return nil, errMissingEntrypoint
+	}
+
+	return &Script{
+		name:      name,
+		thread:    thread,
+		transform: transform,
+	}, nil
+}
+
+// String returns the name of the script, used to identify it in the run summary
+func (s *Script) String() string {
+	return s.name
+}
+
+// Run calls the transform function of the script with the message to produce, and the consumed message it comes from.
+// Concurrent calls are serialized, since the script may keep state in its globals.
+func (s *Script) Run(msg *sarama.ProducerMessage, source *sarama.ConsumerMessage) ([]*sarama.ProducerMessage, error) {
+	record, err := toRecord(msg, source)
+	if err != nil {
+		return nil, err
+	}
+
+	//The returned records may be globals of the script as well, they are converted before the next call
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := starlark.Call(s.thread, s.transform, starlark.Tuple{record}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := result.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlark.Dict:
+		out, err := fromRecord(v, msg.Topic, source)
+		if err != nil {
+			return nil, err
+		}
+		return []*sarama.ProducerMessage{out}, nil
+	case starlark.Indexable:
+		msgs := make([]*sarama.ProducerMessage, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			d, ok := v.Index(i).(*starlark.Dict)
+			if !ok {
+				return nil, errInvalidScriptValue
+			}
+			out, err := fromRecord(d, msg.Topic, source)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, out)
+		}
+		return msgs, nil
+	default:
+		return nil, errInvalidScriptValue
+	}
+}
+
+func toRecord(msg *sarama.ProducerMessage, source *sarama.ConsumerMessage) (*starlark.Dict, error) {
+	key, err := encoded(msg.Key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := encoded(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	//The headers are pairs rather than a dict, a name being possibly repeated
+	headers := make([]starlark.Value, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers = append(headers, starlark.Tuple{starlark.String(h.Key), starlark.String(h.Value)})
+	}
+
+	record := starlark.NewDict(7)
+	fields := []struct {
+		name  string
+		value starlark.Value
+	}{
+		{"topic", starlark.String(msg.Topic)},
+		{"key", bytesValue(key)},
+		{"value", bytesValue(value)},
+		{"headers", starlark.NewList(headers)},
+	}
+	for _, f := range fields {
+		if err := record.SetKey(starlark.String(f.name), f.value); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range readOnlyFields(source).Items() {
+		if err := record.SetKey(item[0], item[1]); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// readOnlyFields returns the fields of a record describing its consumed message, which cannot be changed by the scripts
+func readOnlyFields(source *sarama.ConsumerMessage) *starlark.Dict {
+	fields := starlark.NewDict(3)
+	fields.SetKey(starlark.String("timestamp"), starlark.MakeInt64(source.Timestamp.UnixNano()/1e6))
+	fields.SetKey(starlark.String("partition"), starlark.MakeInt(int(source.Partition)))
+	fields.SetKey(starlark.String("offset"), starlark.MakeInt64(source.Offset))
+	return fields
+}
+
+func bytesValue(b []byte) starlark.Value {
+	if b == nil {
+		return starlark.None
+	}
+	return starlark.String(b)
+}
+
+// fromRecord converts a record returned by a script into a message, the unknown and changed read-only fields being rejected
+// rather than ignored
+func fromRecord(record *starlark.Dict, defaultTopic string, source *sarama.ConsumerMessage) (*sarama.ProducerMessage, error) {
+	msg := &sarama.ProducerMessage{Topic: defaultTopic}
+	readOnly := readOnlyFields(source)
+
+	for _, item := range record.Items() {
+		name, _ := starlark.AsString(item[0])
+		v := item[1]
+
+		switch name {
+		case "topic":
+			topic, ok := starlark.AsString(v)
+			if !ok || topic == "" {
+				return nil, fmt.Errorf("%v: topic", errInvalidRecordField)
+			}
+			msg.Topic = topic
+
+		case "key", "value":
+			if v == starlark.None {
+				continue
+			}
+			s, ok := starlark.AsString(v)
+			if !ok {
+				return nil, fmt.Errorf("%v: %s", errInvalidRecordField, name)
+			}
+			if name == "key" {
+				msg.Key = sarama.ByteEncoder(s)
+			} else {
+				msg.Value = sarama.ByteEncoder(s)
+			}
+
+		case "headers":
+			if v == starlark.None {
+				continue
+			}
+			headers, err := fromHeaders(v)
+			if err != nil {
+				return nil, err
+			}
+			msg.Headers = headers
+
+		default:
+			expected, found, _ := readOnly.Get(item[0])
+			if !found {
+				return nil, fmt.Errorf("%v: unknown field %s", errInvalidRecordField, item[0])
+			}
+			if same, err := starlark.Equal(v, expected); err != nil || !same {
+				return nil, fmt.Errorf("%v: %s cannot be changed", errInvalidRecordField, name)
+			}
+		}
+	}
+	return msg, nil
+}
+
+// fromHeaders converts the headers of a record, a list or tuple of (name, value) pairs
+func fromHeaders(v starlark.Value) ([]sarama.RecordHeader, error) {
+	pairs, ok := v.(starlark.Indexable)
+	if !ok {
+		return nil, fmt.Errorf("%v: headers", errInvalidRecordField)
+	}
+
+	headers := make([]sarama.RecordHeader, 0, pairs.Len())
+	for i := 0; i < pairs.Len(); i++ {
+		pair, ok := pairs.Index(i).(starlark.Indexable)
+		if !ok || pair.Len() != 2 {
+			return nil, fmt.Errorf("%v: headers", errInvalidRecordField)
+		}
+		name, nameOk := starlark.AsString(pair.Index(0))
+		value, valueOk := starlark.AsString(pair.Index(1))
+		if !nameOk || !valueOk {
+			return nil, fmt.Errorf("%v: headers", errInvalidRecordField)
+		}
+		headers = append(headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+	}
+	return headers, nil
+}
@@ -0,0 +1,145 @@
+//+build unit
+
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+var source = &sarama.ConsumerMessage{
+	Partition: 3,
+	Offset:    42,
+	Timestamp: time.Unix(1500000000, 0),
+}
+
+func TestScriptRunPassThrough(t *testing.T) {
+	//Arrange
+	script, err := newScript("identity.star", []byte(`
+def transform(record):
+    return record
+`))
+	assert.NoError(t, err)
+	msg := &sarama.ProducerMessage{
+		Topic:   "foo",
+		Key:     sarama.ByteEncoder("key"),
+		Value:   sarama.ByteEncoder("value"),
+		Headers: []sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}, {Key: []byte("trace"), Value: []byte("a")}, {Key: []byte("trace"), Value: []byte("b")}},
+	}
+
+	//Act
+	actual, err := script.Run(msg, source)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*sarama.ProducerMessage{msg}, actual)
+}
+
+func TestScriptRunRoutingAndSplitting(t *testing.T) {
+	//Arrange
+	script, err := newScript("split.star", []byte(`
+def transform(record):
+    order = json.decode(record["value"])
+    if record["partition"] != 3 or record["offset"] != 42 or record["timestamp"] != 1500000000000:
+        fail("unexpected metadata")
+    return [
+        {"topic": "items", "key": item["sku"], "value": json.encode(item), "headers": [("order", order["id"])]}
+        for item in order["items"]
+    ]
+`))
+	assert.NoError(t, err)
+	msg := &sarama.ProducerMessage{
+		Topic: "orders",
+		Value: sarama.ByteEncoder(`{"id":"42","items":[{"sku":"a"},{"sku":"b"}]}`),
+	}
+
+	//Act
+	actual, err := script.Run(msg, source)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*sarama.ProducerMessage{
+		{Topic: "items", Key: sarama.ByteEncoder("a"), Value: sarama.ByteEncoder(`{"sku":"a"}`), Headers: []sarama.RecordHeader{{Key: []byte("order"), Value: []byte("42")}}},
+		{Topic: "items", Key: sarama.ByteEncoder("b"), Value: sarama.ByteEncoder(`{"sku":"b"}`), Headers: []sarama.RecordHeader{{Key: []byte("order"), Value: []byte("42")}}},
+	}, actual)
+}
+
+func TestScriptRunReadOnlyFields(t *testing.T) {
+	//Arrange
+	script, err := newScript("headers.star", []byte(`
+def transform(record):
+    record["headers"] = [h for h in record["headers"] if h[0] != "secret"] + [("copy", "1")]
+    return [record, {"value": record["value"], "offset": record["offset"]}]
+`))
+	assert.NoError(t, err)
+	msg := &sarama.ProducerMessage{
+		Topic:   "foo",
+		Value:   sarama.ByteEncoder("value"),
+		Headers: []sarama.RecordHeader{{Key: []byte("secret"), Value: []byte("a")}, {Key: []byte("tag"), Value: []byte("b")}},
+	}
+
+	//Act
+	actual, err := script.Run(msg, source)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*sarama.ProducerMessage{
+		{Topic: "foo", Value: sarama.ByteEncoder("value"), Headers: []sarama.RecordHeader{{Key: []byte("tag"), Value: []byte("b")}, {Key: []byte("copy"), Value: []byte("1")}}},
+		{Topic: "foo", Value: sarama.ByteEncoder("value")},
+	}, actual)
+}
+
+func TestScriptRunDrop(t *testing.T) {
+	//Arrange
+	script, err := newScript("drop.star", []byte(`
+def transform(record):
+    return None
+`))
+	assert.NoError(t, err)
+
+	//Act
+	actual, err := script.Run(&sarama.ProducerMessage{Topic: "foo"}, source)
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestScriptRunErrors(t *testing.T) {
+	for _, src := range []string{
+		"def transform(record):\n    fail('boom')\n",
+		"def transform(record):\n    return 42\n",
+		"def transform(record):\n    return {'key': 42}\n",
+		"def transform(record):\n    return [record, 'foo']\n",
+		"def transform(record):\n    return {'headers': ['foo']}\n",
+		"def transform(record):\n    return {'headers': {'foo': 'bar'}}\n",
+		"def transform(record):\n    return {'value': 'foo', 'partition': 0}\n",
+		"def transform(record):\n    record['timestamp'] = 0\n    return record\n",
+		"def transform(record):\n    return {'value': 'foo', 'partiton': 1}\n",
+	} {
+		//Arrange
+		script, err := newScript("error.star", []byte(src))
+		assert.NoError(t, err)
+
+		//Act
+		_, err = script.Run(&sarama.ProducerMessage{Topic: "foo"}, source)
+
+		//Assert
+		assert.Error(t, err, src)
+	}
+}
+
+func TestNewScriptErrors(t *testing.T) {
+	//Act
+	_, syntaxErr := newScript("syntax.star", []byte("def transform(record)\n"))
+	_, entrypointErr := newScript("entrypoint.star", []byte("x = 1\n"))
+	_, fileErr := NewScript("does-not-exist.star")
+
+	//Assert
+	assert.Error(t, syntaxErr)
+	assert.Equal(t, errMissingEntrypoint, entrypointErr)
+	assert.Error(t, fileErr)
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+//ShardedProducer produces through several async producers, to send several requests at once while keeping the order of each partition.
+//
+//A producer with more than one in-flight request can reorder the messages of a partition when a request is retried, hence
+//the single in-flight request of buildProducerConfig. Instead, the sharded producer computes the target partition of a message
+//before sending it, and every partition is always produced through the same producer (partition modulo the number of producers).
+//Each producer has its own connections and a single in-flight request, so the partitions keep their order while the producers
+//send in parallel.
+type ShardedProducer struct {
+	client    sarama.Client
+	producers []*Producer
+	hasher    string
+
+	mu           sync.Mutex
+	partitioners map[string]sarama.Partitioner
+}
+
+//NewShardedProducer configures and returns a producer made of the given number of async producers, see NewProducer for
+//maxMessageBytes, onError and onSuccess
+func NewShardedProducer(brokers []string, hasher, compressionType string, maxMessageBytes, shards int, onError func(*sarama.ProducerError), onSuccess func(*sarama.ProducerMessage)) (*ShardedProducer, error) {
+	//The client is only used for the metadata, the number of partitions of the topics
+	client, err := sarama.NewClient(brokers, buildProducerConfig(hasher, compressionType, maxMessageBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ShardedProducer{
+		client:       client,
+		hasher:       hasher,
+		partitioners: make(map[string]sarama.Partitioner),
+	}
+	for i := 0; i < shards; i++ {
+		cfg := buildProducerConfig(ManualPartitioning, compressionType, maxMessageBytes)
+		cfg.Producer.Return.Successes = onSuccess != nil
+		producer, err := sarama.NewAsyncProducer(brokers, cfg)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.producers = append(p.producers, wrapProducer(producer, onError, onSuccess))
+	}
+	return p, nil
+}
+
+//Send partitions the message, and sends it through the producer of its partition. It is safe for concurrent use.
+func (p *ShardedProducer) Send(msg *sarama.ProducerMessage) error {
+	if p.hasher != ManualPartitioning {
+		partition, err := p.partition(msg)
+		if err != nil {
+			return err
+		}
+		msg.Partition = partition
+	}
+	p.producers[shard(msg.Partition, len(p.producers))].Input() <- msg
+	return nil
+}
+
+func (p *ShardedProducer) partition(msg *sarama.ProducerMessage) (int32, error) {
+	partitions, err := p.client.Partitions(msg.Topic)
+	if err != nil {
+		return 0, err
+	}
+
+	//The hash partitioners reuse their hasher, they are not safe for concurrent use
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	partitioner, ok := p.partitioners[msg.Topic]
+	if !ok {
+		partitioner = NewPartitioner(p.hasher, msg.Topic)
+		p.partitioners[msg.Topic] = partitioner
+	}
+	return partitioner.Partition(msg, int32(len(partitions)))
+}
+
+func shard(partition int32, shards int) int {
+	return int(partition) % shards
+}
+
+//Close flushes and closes every producer
+func (p *ShardedProducer) Close() error {
+	var firstErr error
+	for _, producer := range p.producers {
+		if err := producer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := p.client.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
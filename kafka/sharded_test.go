@@ -0,0 +1,206 @@
+//+build unit
+
+package kafka
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+const benchTopic = "bench"
+
+//newMockCluster starts a broker leading every partition of the benchmark topic, and accepting every produce request
+func newMockCluster(t sarama.TestReporter, partitions int32) *sarama.MockBroker {
+	return serveTopic(t, sarama.NewMockBroker(t, 1), partitions)
+}
+
+//newRemoteCluster starts a broker like newMockCluster, as if it was far away: every request waits for the round-trip, and every
+//connection is slowed down to the throughput a TCP window allows over that round-trip
+func newRemoteCluster(t testing.TB, partitions int32) *sarama.MockBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := serveTopic(t, sarama.NewMockBrokerListener(t, 1, remoteListener{listener}), partitions)
+	broker.SetLatency(benchLatency)
+	return broker
+}
+
+func serveTopic(t sarama.TestReporter, broker *sarama.MockBroker, partitions int32) *sarama.MockBroker {
+	metadata := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	for p := int32(0); p < partitions; p++ {
+		metadata.SetLeader(benchTopic, p, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		//Kafka 1.0 producers send version 3 requests
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3),
+	})
+	return broker
+}
+
+func TestShard(t *testing.T) {
+	assert.Equal(t, 0, shard(0, 4))
+	assert.Equal(t, 3, shard(7, 4))
+	assert.Equal(t, 0, shard(5, 1))
+}
+
+func TestShardedProducerSend(t *testing.T) {
+	//Arrange
+	broker := newMockCluster(t, 3)
+	defer broker.Close()
+	producer, err := NewShardedProducer([]string{broker.Addr()}, "murmur2", "none", 0, 2, nil, nil)
+	assert.NoError(t, err)
+	msgs := []*sarama.ProducerMessage{
+		{Topic: benchTopic, Key: sarama.StringEncoder("foo")},
+		{Topic: benchTopic, Key: sarama.StringEncoder("foobar")},
+		{Topic: benchTopic, Key: sarama.StringEncoder("42")},
+	}
+
+	//Act
+	for _, msg := range msgs {
+		assert.NoError(t, producer.Send(msg))
+	}
+	closeErr := producer.Close()
+
+	//Assert
+	assert.NoError(t, closeErr)
+	assert.Equal(t, []int32{2, 0, 1}, []int32{msgs[0].Partition, msgs[1].Partition, msgs[2].Partition})
+}
+
+func TestShardedProducerUnknownTopic(t *testing.T) {
+	//Arrange
+	broker := newMockCluster(t, 1)
+	defer broker.Close()
+	producer, err := NewShardedProducer([]string{broker.Addr()}, "murmur2", "none", 0, 1, nil, nil)
+	assert.NoError(t, err)
+	defer producer.Close()
+
+	//Act
+	err = producer.Send(&sarama.ProducerMessage{Topic: "unknown", Key: sarama.StringEncoder("foo")})
+
+	//Assert
+	assert.Error(t, err)
+}
+
+func TestShardedProducerReportsErrors(t *testing.T) {
+	//Arrange
+	broker := newMockCluster(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()).SetLeader(benchTopic, 0, broker.BrokerID()),
+		"ProduceRequest":  sarama.NewMockProduceResponse(t).SetVersion(3).SetError(benchTopic, 0, sarama.ErrMessageSizeTooLarge),
+	})
+	var failed []*sarama.ProducerError
+	producer, err := NewShardedProducer([]string{broker.Addr()}, "murmur2", "none", 0, 1, func(err *sarama.ProducerError) {
+		failed = append(failed, err)
+	}, nil)
+	assert.NoError(t, err)
+
+	//Act
+	assert.NoError(t, producer.Send(&sarama.ProducerMessage{Topic: benchTopic, Key: sarama.StringEncoder("foo")}))
+	closeErr := producer.Close()
+
+	//Assert
+	assert.NoError(t, closeErr)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, sarama.ErrMessageSizeTooLarge, failed[0].Err)
+}
+
+func TestProducerReportsSuccesses(t *testing.T) {
+	//Arrange
+	broker := newMockCluster(t, 1)
+	defer broker.Close()
+	var produced []*sarama.ProducerMessage
+	producer := NewProducer([]string{broker.Addr()}, "murmur2", "none", 0, nil, func(msg *sarama.ProducerMessage) {
+		produced = append(produced, msg)
+	})
+	msg := &sarama.ProducerMessage{Topic: benchTopic, Key: sarama.StringEncoder("foo"), Metadata: 42}
+
+	//Act
+	producer.Input() <- msg
+	closeErr := producer.Close()
+
+	//Assert
+	assert.NoError(t, closeErr)
+	assert.Equal(t, []*sarama.ProducerMessage{msg}, produced)
+	assert.Equal(t, 42, produced[0].Metadata)
+}
+
+//benchLatency simulates the round-trip to a remote cluster (e.g. in another region), which is what several in-flight requests make up for
+const benchLatency = 20 * time.Millisecond
+
+//benchBandwidth is the throughput of a single connection to the remote cluster, in bytes per second: a TCP window of 256KB
+//per round-trip. Without it, a single request carries everything produced during the previous round-trip, whatever its size.
+const benchBandwidth = 256 * 1024 * int64(time.Second/benchLatency)
+
+type remoteListener struct {
+	net.Listener
+}
+
+func (l remoteListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &remoteConn{Conn: conn}, nil
+}
+
+//remoteConn reads no faster than benchBandwidth
+type remoteConn struct {
+	net.Conn
+	start time.Time
+	read  int64
+}
+
+func (c *remoteConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.start.IsZero() {
+		c.start = time.Now()
+	}
+	c.read += int64(n)
+	time.Sleep(time.Until(c.start.Add(time.Duration(c.read * int64(time.Second) / benchBandwidth))))
+	return n, err
+}
+
+func BenchmarkProducer(b *testing.B) {
+	broker := newRemoteCluster(b, 8)
+	defer broker.Close()
+	value := sarama.ByteEncoder(make([]byte, 1024))
+
+	producer := NewProducer([]string{broker.Addr()}, "murmur2", "none", 0, nil, nil)
+	b.SetBytes(int64(len(value)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		producer.Input() <- &sarama.ProducerMessage{Topic: benchTopic, Key: sarama.StringEncoder(fmt.Sprint(i)), Value: value}
+	}
+	producer.Close()
+}
+
+func BenchmarkShardedProducer(b *testing.B) {
+	for _, shards := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d producers", shards), func(b *testing.B) {
+			broker := newRemoteCluster(b, 8)
+			defer broker.Close()
+			value := sarama.ByteEncoder(make([]byte, 1024))
+
+			producer, err := NewShardedProducer([]string{broker.Addr()}, "murmur2", "none", 0, shards, nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(value)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				producer.Send(&sarama.ProducerMessage{Topic: benchTopic, Key: sarama.StringEncoder(fmt.Sprint(i)), Value: value})
+			}
+			producer.Close()
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	errUnknownTransformer    = errors.New("unknown transformer")
+	errInvalidTransformer    = errors.New("invalid transformer, expected name or name:argument")
+	errTransformerRegistered = errors.New("transformer already registered")
+	errMissingTransformerArg = errors.New("transformer requires an argument")
+	errInvalidReplaceArg     = errors.New("invalid replace-value argument, expected pattern=>replacement")
+	errKeyFieldNotFound      = errors.New("key field not found in value")
+)
+
+// Transformer alters a message between its consumption and its production.
+// Returning an error prevents the message from being cloned.
+type Transformer interface {
+	Transform(msg *sarama.ProducerMessage) error
+}
+
+// TransformerFunc allows using ordinary functions as transformers
+type TransformerFunc func(msg *sarama.ProducerMessage) error
+
+// Transform calls f(msg)
+func (f TransformerFunc) Transform(msg *sarama.ProducerMessage) error {
+	return f(msg)
+}
+
+// TransformerFactory builds a transformer from the argument given in its specification
+type TransformerFactory func(arg string) (Transformer, error)
+
+var (
+	transformersMu sync.RWMutex
+	transformers   = map[string]TransformerFactory{
+		"key-from-json": newKeyFromJSONTransformer,
+		"set-header":    newSetHeaderTransformer,
+		"remove-header": newRemoveHeaderTransformer,
+		"drop-field":    newDropFieldTransformer,
+		"replace-value": newReplaceValueTransformer,
+	}
+)
+
+// RegisterTransformer makes a transformer available under the given name,
+// so that it can be used in specifications passed to NewTransformer.
+func RegisterTransformer(name string, factory TransformerFactory) error {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	if _, ok := transformers[name]; ok {
+		return fmt.Errorf("%v: %s", errTransformerRegistered, name)
+	}
+	transformers[name] = factory
+	return nil
+}
+
+// Transformers returns the sorted names of the registered transformers
+func Transformers() []string {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTransformer builds a registered transformer from its specification, "name" or "name:argument"
+func NewTransformer(spec string) (Transformer, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if parts[0] == "" {
+		return nil, errInvalidTransformer
+	}
+
+	transformersMu.RLock()
+	factory, ok := transformers[parts[0]]
+	transformersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%v: %s", errUnknownTransformer, parts[0])
+	}
+
+	var arg string
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+	return factory(arg)
+}
+
+// Chain applies its transformers in order, stopping at the first error
+type Chain []Transformer
+
+// NewChain builds a chain from transformer specifications, see NewTransformer
+func NewChain(specs []string) (Chain, error) {
+	chain := make(Chain, 0, len(specs))
+	for _, spec := range specs {
+		t, err := NewTransformer(spec)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, t)
+	}
+	return chain, nil
+}
+
+// Transform implements Transformer
+func (c Chain) Transform(msg *sarama.ProducerMessage) error {
+	for _, t := range c {
+		if err := t.Transform(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encoded returns the raw bytes behind a message key or value
+func encoded(e sarama.Encoder) ([]byte, error) {
+	if e == nil {
+		return nil, nil
+	}
+	return e.Encode()
+}
+
+func newKeyFromJSONTransformer(arg string) (Transformer, error) {
+	steps, err := parseJSONPath(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+		value, err := encoded(msg.Value)
+		if err != nil || value == nil {
+			return errKeyFieldNotFound
+		}
+		doc, err := decodeJSON(value)
+		if err != nil {
+			return err
+		}
+		field, found := lookupJSONPath(doc, steps)
+		if !found {
+			return errKeyFieldNotFound
+		}
+		msg.Key = sarama.StringEncoder(jsonValueString(field))
+		return nil
+	}), nil
+}
+
+func newSetHeaderTransformer(arg string) (Transformer, error) {
+	parts := strings.SplitN(arg, "=", 2)
+	if parts[0] == "" || len(parts) != 2 {
+		return nil, fmt.Errorf("%v: set-header:name=value", errMissingTransformerArg)
+	}
+	name, value := []byte(parts[0]), []byte(parts[1])
+
+	return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+		removeHeader(msg, name)
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: name, Value: value})
+		return nil
+	}), nil
+}
+
+func newRemoveHeaderTransformer(arg string) (Transformer, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("%v: remove-header:name", errMissingTransformerArg)
+	}
+	name := []byte(arg)
+
+	return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+		removeHeader(msg, name)
+		return nil
+	}), nil
+}
+
+func removeHeader(msg *sarama.ProducerMessage, name []byte) {
+	headers := msg.Headers[:0]
+	for _, h := range msg.Headers {
+		if !bytes.Equal(h.Key, name) {
+			headers = append(headers, h)
+		}
+	}
+	msg.Headers = headers
+}
+
+func newDropFieldTransformer(arg string) (Transformer, error) {
+	steps, err := parseJSONPath(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+		value, err := encoded(msg.Value)
+		if err != nil || value == nil {
+			return err
+		}
+		//The value is only decoded to be validated, the field being spliced out of its bytes
+		if _, err := decodeJSON(value); err != nil {
+			return err
+		}
+		spliced, deleted := spliceJSONPath(value, steps)
+		if !deleted {
+			return nil
+		}
+		msg.Value = sarama.ByteEncoder(spliced)
+		return nil
+	}), nil
+}
+
+func newReplaceValueTransformer(arg string) (Transformer, error) {
+	parts := strings.SplitN(arg, "=>", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, errInvalidReplaceArg
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	replacement := []byte(parts[1])
+
+	return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+		value, err := encoded(msg.Value)
+		if err != nil || value == nil {
+			return err
+		}
+		msg.Value = sarama.ByteEncoder(re.ReplaceAll(value, replacement))
+		return nil
+	}), nil
+}
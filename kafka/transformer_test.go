@@ -0,0 +1,122 @@
+//+build unit
+
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type transformerTest struct {
+	spec            string
+	msg             *sarama.ProducerMessage
+	expectedKey     sarama.Encoder
+	expectedValue   sarama.Encoder
+	expectedHeaders []sarama.RecordHeader
+	expectedErr     bool
+}
+
+var transformerTestCases = []transformerTest{
+	{
+		spec:          "key-from-json:$.customer.id",
+		msg:           &sarama.ProducerMessage{Key: sarama.StringEncoder("foo"), Value: sarama.ByteEncoder(`{"customer":{"id":42}}`)},
+		expectedKey:   sarama.StringEncoder("42"),
+		expectedValue: sarama.ByteEncoder(`{"customer":{"id":42}}`),
+	},
+	{
+		spec:        "key-from-json:$.customer.id",
+		msg:         &sarama.ProducerMessage{Key: sarama.StringEncoder("foo"), Value: sarama.ByteEncoder(`{"customer":{}}`)},
+		expectedErr: true,
+	},
+	{
+		spec:            "set-header:source=cloner",
+		msg:             &sarama.ProducerMessage{Headers: []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("app")}, {Key: []byte("type"), Value: []byte("created")}}},
+		expectedHeaders: []sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}, {Key: []byte("source"), Value: []byte("cloner")}},
+	},
+	{
+		spec:            "remove-header:source",
+		msg:             &sarama.ProducerMessage{Headers: []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("app")}, {Key: []byte("type"), Value: []byte("created")}}},
+		expectedHeaders: []sarama.RecordHeader{{Key: []byte("type"), Value: []byte("created")}},
+	},
+	{
+		spec:          "drop-field:customer.email",
+		msg:           &sarama.ProducerMessage{Value: sarama.ByteEncoder(`{"customer":{"email":"foo@bar.com","id":42}}`)},
+		expectedValue: sarama.ByteEncoder(`{"customer":{"id":42}}`),
+	},
+	{
+		spec:          "drop-field:items[0]",
+		msg:           &sarama.ProducerMessage{Value: sarama.ByteEncoder(`{"items":[1,2,3]}`)},
+		expectedValue: sarama.ByteEncoder(`{"items":[2,3]}`),
+	},
+	{
+		spec:          "drop-field:customer.email",
+		msg:           &sarama.ProducerMessage{Value: sarama.ByteEncoder(`{"url": "/a?b=1&c=<d>", "customer": {"id": 42, "email": "foo@bar.com"}, "id": 1.50}`)},
+		expectedValue: sarama.ByteEncoder(`{"url": "/a?b=1&c=<d>", "customer": {"id": 42}, "id": 1.50}`),
+	},
+	{
+		spec:          "drop-field:missing",
+		msg:           &sarama.ProducerMessage{Value: sarama.ByteEncoder(`{"id": 42}`)},
+		expectedValue: sarama.ByteEncoder(`{"id": 42}`),
+	},
+	{
+		spec:          "replace-value:staging-([a-z]+)=>production-$1",
+		msg:           &sarama.ProducerMessage{Value: sarama.ByteEncoder(`{"url":"staging-foo"}`)},
+		expectedValue: sarama.ByteEncoder(`{"url":"production-foo"}`),
+	},
+}
+
+func TestTransformers(t *testing.T) {
+	for _, v := range transformerTestCases {
+		//Arrange
+		transformer, err := NewTransformer(v.spec)
+		assert.NoError(t, err, v.spec)
+
+		//Act
+		err = transformer.Transform(v.msg)
+
+		//Assert
+		if v.expectedErr {
+			assert.Error(t, err, v.spec)
+			continue
+		}
+		assert.NoError(t, err, v.spec)
+		assert.Equal(t, v.expectedKey, v.msg.Key, v.spec)
+		assert.Equal(t, v.expectedValue, v.msg.Value, v.spec)
+		assert.Equal(t, v.expectedHeaders, v.msg.Headers, v.spec)
+	}
+}
+
+func TestNewTransformerErrors(t *testing.T) {
+	for _, spec := range []string{"", "unknown", "set-header", "set-header:foo", "remove-header", "drop-field", "replace-value:foo", "replace-value:(=>bar"} {
+		//Act
+		_, err := NewTransformer(spec)
+
+		//Assert
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRegisterTransformer(t *testing.T) {
+	//Arrange
+	errDropped := errors.New("dropped")
+	factory := func(arg string) (Transformer, error) {
+		return TransformerFunc(func(msg *sarama.ProducerMessage) error {
+			return errDropped
+		}), nil
+	}
+
+	//Act
+	err := RegisterTransformer("test-drop", factory)
+	duplicateErr := RegisterTransformer("test-drop", factory)
+	chain, chainErr := NewChain([]string{"set-header:foo=bar", "test-drop"})
+
+	//Assert
+	assert.NoError(t, err)
+	assert.Error(t, duplicateErr)
+	assert.NoError(t, chainErr)
+	assert.Contains(t, Transformers(), "test-drop")
+	assert.Equal(t, errDropped, chain.Transform(&sarama.ProducerMessage{}))
+}